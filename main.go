@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/arun0009/go-resilience-mock/pkg/config"
 	"github.com/arun0009/go-resilience-mock/pkg/observability"
@@ -13,10 +17,20 @@ import (
 
 func main() {
 	log.SetFlags(0)
+
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
+	}
+
 	log.Println("Initializing Go Resilience Mock Server...")
 
-	// 1. Load Configuration
-	cfg, err := config.LoadConfig("scenarios.yaml")
+	// 1. Load Configuration (flags > env > config file > defaults)
+	fs := flag.NewFlagSet("go-resilience-mock", flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "Config file with server settings and scenarios (env CONFIG_FILE)")
+	config.BindFlags(fs)
+	_ = fs.Parse(os.Args[1:])
+
+	cfg, err := config.Load(config.LoadOptions{ConfigFile: *configFile, Flags: fs})
 	if err != nil {
 		log.Fatalf("Fatal: Failed to load config: %v", err)
 	}
@@ -24,6 +38,19 @@ func main() {
 	// 2. Initialize Observability
 	observability.InitMetrics()
 
+	// 2a. Watch the scenario files for changes
+	if cfg.ReloadInterval > 0 {
+		go config.WatchScenarios(context.Background(), cfg.ReloadInterval, func(err error) {
+			if err != nil {
+				observability.ScenarioReloads.WithLabelValues("failure").Inc()
+				log.Printf("Scenario reload failed, keeping previous scenarios: %v", err)
+				return
+			}
+			observability.ScenarioReloads.WithLabelValues("success").Inc()
+			log.Println("Reloaded scenarios")
+		})
+	}
+
 	// 3. Register Prometheus Handler
 	http.Handle("/metrics", promhttp.Handler())
 
@@ -45,3 +72,26 @@ func main() {
 		log.Fatalf("Server failed to run: %v", server.Run(cfg))
 	}
 }
+
+// runValidate implements `go-resilience-mock validate [--strict] <files...>`. It checks scenario
+// files or directories without starting the server and returns the process exit code.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	strict := fs.Bool("strict", false, "Reject unknown fields")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: go-resilience-mock validate [--strict] <file-or-dir>...")
+		return 2
+	}
+
+	scenarios, err := config.ValidateScenarioFiles(*strict, paths...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("OK: %d scenarios\n", len(scenarios))
+	return 0
+}
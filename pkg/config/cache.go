@@ -0,0 +1,75 @@
+package config
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MaxCompiledPatterns caps each cache of compiled patterns. The admin API can bring in new
+// patterns at any rate, so once the cap is reached the least recently used pattern is dropped,
+// and compiled again if a scenario still needs it.
+const MaxCompiledPatterns = 4096
+
+// CompileCache holds the results of compile for the most recently used patterns, at most
+// MaxCompiledPatterns of them. Patterns that fail to compile are not cached.
+type CompileCache[T any] struct {
+	compile func(string) (T, error)
+	mutex   sync.Mutex
+	entries map[string]*list.Element // Values are *compiledEntry[T]
+	recent  list.List                // Most recently used first
+}
+
+type compiledEntry[T any] struct {
+	pattern string
+	value   T
+}
+
+// NewCompileCache returns an empty cache of the results of compile
+func NewCompileCache[T any](compile func(string) (T, error)) *CompileCache[T] {
+	return &CompileCache[T]{compile: compile, entries: make(map[string]*list.Element)}
+}
+
+// Get returns the compiled pattern, compiling it on first use
+func (c *CompileCache[T]) Get(pattern string) (T, error) {
+	if value, ok := c.lookup(pattern); ok {
+		return value, nil
+	}
+	// Compiled without the lock, so a slow pattern does not hold up requests using others
+	value, err := c.compile(pattern)
+	if err != nil {
+		return value, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.entries[pattern]; ok {
+		// Compiled concurrently by another caller
+		c.recent.MoveToFront(e)
+		return e.Value.(*compiledEntry[T]).value, nil
+	}
+	if c.recent.Len() >= MaxCompiledPatterns {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.entries, oldest.Value.(*compiledEntry[T]).pattern)
+	}
+	c.entries[pattern] = c.recent.PushFront(&compiledEntry[T]{pattern: pattern, value: value})
+	return value, nil
+}
+
+func (c *CompileCache[T]) lookup(pattern string) (T, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.entries[pattern]; ok {
+		c.recent.MoveToFront(e)
+		return e.Value.(*compiledEntry[T]).value, true
+	}
+	var zero T
+	return zero, false
+}
+
+// Len returns the number of cached patterns
+func (c *CompileCache[T]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.recent.Len()
+}
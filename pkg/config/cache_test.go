@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileCache(t *testing.T) {
+	compiled := 0
+	c := NewCompileCache(func(pattern string) (int, error) {
+		compiled++
+		if pattern == "bad" {
+			return 0, errors.New("invalid pattern")
+		}
+		return strconv.Atoi(pattern)
+	})
+
+	v, err := c.Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	_, _ = c.Get("1")
+	assert.Equal(t, 1, compiled, "A pattern is compiled once")
+
+	_, err = c.Get("bad")
+	assert.Error(t, err)
+	assert.Equal(t, 1, c.Len(), "Failures are not cached")
+}
+
+func TestCompileCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	compiled := make(map[string]int)
+	c := NewCompileCache(func(pattern string) (string, error) {
+		compiled[pattern]++
+		return pattern, nil
+	})
+	for i := 0; i < MaxCompiledPatterns; i++ {
+		_, _ = c.Get(strconv.Itoa(i))
+	}
+	_, _ = c.Get("0") // Used again, so 1 is now the least recently used
+
+	_, _ = c.Get("new")
+	assert.Equal(t, MaxCompiledPatterns, c.Len(), "The number of patterns is capped")
+	_, _ = c.Get("0")
+	assert.Equal(t, 1, compiled["0"], "Recently used patterns are kept")
+	_, _ = c.Get("1")
+	assert.Equal(t, 2, compiled["1"], "The least recently used pattern was evicted")
+}
@@ -2,11 +2,13 @@ package config
 
 import (
 	"encoding/json"
-	"log"
+	"flag"
+	"fmt"
+	"math"
 	"net/http"
 	"os"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -20,6 +22,7 @@ type Config struct {
 	EnableTLS              bool          `yaml:"enableTLS"`
 	CertFile               string        `yaml:"certFile"`
 	KeyFile                string        `yaml:"keyFile"`
+	ClientCAFile           string        `yaml:"clientCAFile"` // CA bundle for verifying optional TLS client certificates
 	EnableCORS             bool          `yaml:"enableCORS"`
 	LogRequests            bool          `yaml:"logRequests"`
 	LogHeaders             bool          `yaml:"logHeaders"`
@@ -30,14 +33,28 @@ type Config struct {
 	HistorySize            int           `yaml:"historySize"`
 	GlobalDelay            time.Duration `yaml:"globalDelay"`
 	GlobalChaosProbability float64       `yaml:"globalChaosProbability"`
-	Scenarios              []Scenario    `yaml:"-"` // Handled separately
+	ScenariosPath          string        `yaml:"scenariosPath"`   // Scenario file or directory
+	ReloadInterval         time.Duration `yaml:"reloadInterval"`  // Scenario file poll interval, 0 disables hot reload
+	Profile                string        `yaml:"profile"`         // Active scenario profile, empty for the base set
+	StrictScenarios        bool          `yaml:"strictScenarios"` // Reject unknown fields in scenario files
+	Scenarios              []Scenario    `yaml:"-"`               // Loaded by the scenario loader (see loader.go)
+}
+
+// LoadOptions controls where Load reads server settings and scenarios from.
+// Precedence is flags > env vars > config file > defaults.
+type LoadOptions struct {
+	ConfigFile string        // Optional config file holding server settings and scenarios
+	Flags      *flag.FlagSet // Parsed flags registered with BindFlags, may be nil
+
+	// ScenariosPath overrides the scenarios path from every other source when set
+	ScenariosPath string
 }
 
 // CircuitBreakerConfig defines the configuration for the circuit breaker
 type CircuitBreakerConfig struct {
-	FailureThreshold int           `yaml:"failureThreshold"`
-	SuccessThreshold int           `yaml:"successThreshold"`
-	Timeout          time.Duration `yaml:"timeout"`
+	FailureThreshold int           `yaml:"failureThreshold,omitempty" json:"failureThreshold,omitempty"`
+	SuccessThreshold int           `yaml:"successThreshold,omitempty" json:"successThreshold,omitempty"`
+	Timeout          time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 }
 
 // CircuitBreakerState tracks the runtime state of the circuit breaker
@@ -50,6 +67,39 @@ type CircuitBreakerState struct {
 	Mutex          sync.Mutex
 }
 
+// Response selection strategies
+const (
+	StrategySequential = "sequential"  // Responses in order, wrapping around (default)
+	StrategyRandom     = "random"      // Any response, uniformly
+	StrategyWeighted   = "weighted"    // Any response, in proportion to its weight
+	StrategyShuffle    = "shuffle"     // Every response once per round, in random order
+	StrategyStickyLast = "sticky-last" // Responses in order, then the last one forever
+)
+
+// Strategies lists the valid values of Scenario.Strategy
+var Strategies = []string{StrategySequential, StrategyRandom, StrategyWeighted, StrategyShuffle, StrategyStickyLast}
+
+// End-of-sequence behaviors of the sequential strategy
+const (
+	ExhaustedLoop        = "loop"        // Start over with the first response (default)
+	ExhaustedStickLast   = "stickLast"   // Serve the last response from then on
+	ExhaustedFallthrough = "fallthrough" // Pass requests on to the next matching scenario, or echo
+	ExhaustedNotFound    = "404"         // Respond 404 Not Found
+)
+
+// ExhaustedBehaviors lists the valid values of Scenario.OnExhausted
+var ExhaustedBehaviors = []string{ExhaustedLoop, ExhaustedStickLast, ExhaustedFallthrough, ExhaustedNotFound}
+
+// MaxSequenceSteps caps the steps of a sequence, counting each response times times,
+// so the position in the sequence fits in SequenceState.Index
+const MaxSequenceSteps = math.MaxInt32
+
+// ShuffleState tracks the current round of the shuffle strategy
+type ShuffleState struct {
+	Order []int // Response indexes not yet served in this round
+	Mutex sync.Mutex
+}
+
 // JSONBody is a helper type to handle both string and structured JSON in YAML
 type JSONBody json.RawMessage
 
@@ -75,11 +125,21 @@ func (j *JSONBody) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
-// MarshalJSON implements the json.Marshaler interface
+// MarshalYAML implements the yaml.Marshaler interface.
+// Bodies are written as strings, which UnmarshalYAML reads back unchanged.
+func (j JSONBody) MarshalYAML() (interface{}, error) {
+	return string(j), nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+// Bodies that are not valid JSON (e.g. plain text from YAML) are encoded as JSON strings.
 func (j JSONBody) MarshalJSON() ([]byte, error) {
 	if len(j) == 0 {
 		return []byte("null"), nil
 	}
+	if !json.Valid(j) {
+		return json.Marshal(string(j))
+	}
 	return json.RawMessage(j).MarshalJSON()
 }
 
@@ -91,31 +151,120 @@ func (j *JSONBody) UnmarshalJSON(data []byte) error {
 
 // MatchConfig defines rules for matching a request to a scenario
 type MatchConfig struct {
-	Headers map[string]string `yaml:"headers"`
-	Query   map[string]string `yaml:"query"`
-	Body    JSONBody          `yaml:"body"`
+	Headers map[string]StringMatcher `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Query   map[string]StringMatcher `yaml:"query,omitempty" json:"query,omitempty"`
+	Body    BodyMatcher              `yaml:"body,omitempty" json:"body,omitzero"`
+
+	// Callers: target a single client while others see normal responses
+	Cookies           map[string]StringMatcher `yaml:"cookies,omitempty" json:"cookies,omitempty"`
+	Host              StringMatcher            `yaml:"host,omitempty" json:"host,omitzero"`                           // Host header without the port
+	ContentType       StringMatcher            `yaml:"contentType,omitempty" json:"contentType,omitzero"`             // Media type without parameters, e.g. application/json
+	ClientIP          []string                 `yaml:"clientIp,omitempty" json:"clientIp,omitempty"`                  // IPs or CIDR ranges, any of which must contain the client IP
+	ClientCertSubject StringMatcher            `yaml:"clientCertSubject,omitempty" json:"clientCertSubject,omitzero"` // Subject of the TLS client certificate, e.g. CN=checkout,O=Acme
+
+	// JSON bodies: values selected by JSONPath, or the whole body compared semantically
+	JSONBody          []JSONPathMatcher `yaml:"jsonBody,omitempty" json:"jsonBody,omitempty"`
+	EqualToJSON       JSONValue         `yaml:"equalToJson,omitempty" json:"equalToJson,omitempty"`
+	IgnoreExtraFields bool              `yaml:"ignoreExtraFields,omitempty" json:"ignoreExtraFields,omitempty"` // Allow object members not in equalToJson
+	IgnoreArrayOrder  bool              `yaml:"ignoreArrayOrder,omitempty" json:"ignoreArrayOrder,omitempty"`   // Compare arrays in equalToJson as multisets
+
+	// GraphQL requests: operation name, type and variables
+	GraphQL GraphQLMatcher `yaml:"graphql,omitempty" json:"graphql,omitzero"`
+
+	// Form bodies: fields of form-encoded or multipart bodies, and multipart parts
+	Form      map[string]StringMatcher `yaml:"form,omitempty" json:"form,omitempty"` // Form fields; multipart file parts are matched by multipart
+	Multipart []PartMatcher            `yaml:"multipart,omitempty" json:"multipart,omitempty"`
+
+	// Combinators, evaluated after the matchers above
+	AnyOf []MatchConfig `yaml:"anyOf,omitempty" json:"anyOf,omitempty"` // At least one block must match
+	AllOf []MatchConfig `yaml:"allOf,omitempty" json:"allOf,omitempty"` // Every block must match
+	Not   MatchList     `yaml:"not,omitempty" json:"not,omitempty"`     // No block may match
+}
+
+// Count returns the number of request matchers, used to rank more specific scenarios first
+func (m *MatchConfig) Count() int {
+	count := len(m.Headers) + len(m.Query) + len(m.Cookies) + len(m.JSONBody) + len(m.Form) + len(m.Multipart)
+	minAnyOf := 0
+	for _, sm := range []StringMatcher{m.Host, m.ContentType, m.ClientCertSubject} {
+		if !sm.IsZero() {
+			count++
+		}
+	}
+	if len(m.ClientIP) > 0 {
+		count++
+	}
+	if !m.Body.IsZero() {
+		count++
+	}
+	if len(m.EqualToJSON) > 0 {
+		count++
+	}
+	count += m.GraphQL.Count()
+	for i := range m.AllOf {
+		count += m.AllOf[i].Count()
+	}
+	// Only one alternative has to match, so anyOf is as specific as its least specific block
+	for i := range m.AnyOf {
+		if c := m.AnyOf[i].Count(); i == 0 || c < minAnyOf {
+			minAnyOf = c
+		}
+	}
+	return count + minAnyOf + len(m.Not)
 }
 
 // Scenario defines a sequence of custom responses for a specific path
 type Scenario struct {
-	Path           string               `yaml:"path"`
-	Method         string               `yaml:"method"`
-	Matches        MatchConfig          `yaml:"matches"`
-	Responses      []Response           `yaml:"responses"`
-	CircuitBreaker CircuitBreakerConfig `yaml:"circuitBreaker"`
-	CBState        *CircuitBreakerState `yaml:"-"` // Runtime state
-	Index          int32                // Current response index (atomic operations)
+	ID             string               `yaml:"id,omitempty" json:"id,omitempty"` // Stable ID, generated when empty
+	Path           string               `yaml:"path" json:"path"`
+	Method         string               `yaml:"method" json:"method"`
+	Disabled       bool                 `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	Priority       int                  `yaml:"priority,omitempty" json:"priority,omitempty"` // Higher priorities are evaluated first
+	Matches        MatchConfig          `yaml:"matches,omitempty" json:"matches"`
+	Responses      []Response           `yaml:"responses" json:"responses"`
+	Strategy       string               `yaml:"strategy,omitempty" json:"strategy,omitempty"`       // How responses are picked, see Strategies
+	OnExhausted    string               `yaml:"onExhausted,omitempty" json:"onExhausted,omitempty"` // What follows the last response, see ExhaustedBehaviors
+	SequenceKey    SequenceKey          `yaml:"sequenceKey,omitempty" json:"sequenceKey,omitzero"`
+	StateMachine   string               `yaml:"stateMachine,omitempty" json:"stateMachine,omitempty"` // State machine of whenState and setState, default "default"
+	WhenState      string               `yaml:"whenState,omitempty" json:"whenState,omitempty"`       // Only match while the state machine is in this state
+	SetState       string               `yaml:"setState,omitempty" json:"setState,omitempty"`         // State to move to after serving a response
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuitBreaker,omitempty" json:"circuitBreaker"`
+	Source         string               `yaml:"-" json:"source,omitempty"`   // File the scenario was loaded from, empty if added at runtime
+	Profile        string               `yaml:"-" json:"profile,omitempty"`  // Profile the scenario belongs to, empty for the base set
+	Resource       string               `yaml:"-" json:"resource,omitempty"` // Path of the resource whose operation the scenario serves
+	Runtime        *ScenarioRuntime     `yaml:"-" json:"-"`                  // Runtime state, shared by copies of the scenario
 }
 
 // Response defines a custom response
 type Response struct {
-	Status      int               `yaml:"status"`
-	Delay       time.Duration     `yaml:"delay"`
-	DelayRange  string            `yaml:"delayRange"` // e.g., "100ms-500ms"
-	Body        JSONBody          `yaml:"body"`
-	Headers     map[string]string `yaml:"headers"`
-	Gzip        bool              `yaml:"gzip"`
-	Probability float64           `yaml:"probability"`
+	Status        int               `yaml:"status" json:"status"`
+	Delay         time.Duration     `yaml:"delay,omitempty" json:"delay,omitempty"`
+	DelayRange    string            `yaml:"delayRange,omitempty" json:"delayRange,omitempty"` // e.g., "100ms-500ms"
+	Body          JSONBody          `yaml:"body,omitempty" json:"body,omitzero"`
+	BodyFile      string            `yaml:"bodyFile,omitempty" json:"bodyFile,omitempty"` // Body read from a file, relative to the scenario file
+	Headers       map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Gzip          bool              `yaml:"gzip,omitempty" json:"gzip,omitempty"`
+	Probability   float64           `yaml:"probability,omitempty" json:"probability,omitempty"`
+	Weight        int               `yaml:"weight,omitempty" json:"weight,omitempty"`               // Relative weight for the weighted strategy, default 1
+	Times         int               `yaml:"times,omitempty" json:"times,omitempty"`                 // Consecutive requests served in a sequence, default 1
+	SetState      string            `yaml:"setState,omitempty" json:"setState,omitempty"`           // Overrides the scenario's setState when this response is served
+	GraphQLErrors []GraphQLError    `yaml:"graphqlErrors,omitempty" json:"graphqlErrors,omitempty"` // Sends {"data": body, "errors": [...]}
+	BodyPath      string            `yaml:"-" json:"-"`                                             // BodyFile resolved against the scenario file's directory
+}
+
+// GraphQLError is an entry of the errors array of a GraphQL response
+type GraphQLError struct {
+	Message    string    `yaml:"message" json:"message"`
+	Path       JSONValue `yaml:"path,omitempty" json:"path,omitempty"`             // Field that failed, e.g. [hero, friends, 0]
+	Extensions JSONValue `yaml:"extensions,omitempty" json:"extensions,omitempty"` // e.g. {code: UNAVAILABLE}
+}
+
+// BodyFilePath returns the path of the response body file, or "" if the body is inline.
+// Body files of scenarios added at runtime are relative to the working directory.
+func (r *Response) BodyFilePath() string {
+	if r.BodyPath != "" {
+		return r.BodyPath
+	}
+	return r.BodyFile
 }
 
 // RequestRecord stores details of a recorded request
@@ -147,151 +296,116 @@ var (
 		HistorySize:            100,
 		GlobalDelay:            0,
 		GlobalChaosProbability: 0.0,
+		ScenariosPath:          "scenarios.yaml",
+		ReloadInterval:         2 * time.Second,
 	}
 
-	configLock     sync.Mutex
-	currentConfig  Config
-	scenarios      sync.Map // map[string][]*Scenario (key: path_method)
-	RequestHistory []RequestRecord
-	HistoryMutex   sync.Mutex
-	RequestCounter uint64
-	rateLimiter    *rate.Limiter
-	registry       *prometheus.Registry
+	configLock      sync.Mutex
+	currentConfig   Config
+	scenarios       atomic.Pointer[sync.Map] // map[string][]*Scenario (key: path_method), swapped on reload
+	scenarioOrder   atomic.Pointer[ScenarioOrder]
+	fileScenarios   []*Scenario // Live scenarios loaded from scenario files (caller must hold configLock)
+	loadedScenarios []Scenario  // Definitions of every loaded file scenario, including inactive profiles
+	activeProfile   string      // Active scenario profile, empty for the base set
+	scenarioPaths   []string    // Configured scenario sources, re-read on reload
+	scenarioFiles   []string    // Files read by the last scenario load, including includes
+	RequestHistory  []RequestRecord
+	HistoryMutex    sync.Mutex
+	RequestCounter  uint64
+	rateLimiter     *rate.Limiter
+	registry        *prometheus.Registry
 )
 
 func init() {
 	// Initialize with defaults
 	currentConfig = DefaultConfig
+	scenarios.Store(&sync.Map{})
+	scenarioOrder.Store(&ScenarioOrder{})
 	registry = prometheus.NewRegistry()
 	// mrand.Seed is deprecated in Go 1.20+ and no longer needed for global rand
 }
 
-// LoadConfig loads server configuration from the main config file and scenario file
-func LoadConfig(scenarioFile string) (Config, error) {
+// LoadConfig loads server configuration from defaults and env vars, and scenarios from
+// scenarioPath, which may be a single scenario file or a directory of *.yaml/*.yml/*.json files
+func LoadConfig(scenarioPath string) (Config, error) {
+	return Load(LoadOptions{ScenariosPath: scenarioPath})
+}
+
+// Load builds the server configuration from defaults, the optional config file, env vars
+// and flags (in increasing order of precedence), then loads scenarios from the config file
+// and the scenarios path. Any invalid value makes Load fail.
+func Load(opts LoadOptions) (Config, error) {
 	configLock.Lock()
 	defer configLock.Unlock()
 
-	// 1. Load Scenarios
-	data, err := os.ReadFile(scenarioFile)
-	if err != nil {
-		log.Printf("Warning: Failed to read %s, running without custom scenarios: %v", scenarioFile, err)
-	} else {
-		var loadedScenarios []Scenario
-		if err := yaml.Unmarshal(data, &loadedScenarios); err != nil {
-			return Config{}, err
-		}
+	// 1. Defaults
+	cfg := DefaultConfig
+	if h, err := os.Hostname(); err == nil {
+		cfg.Hostname = h
+	}
 
-		for i := range loadedScenarios {
-			// Initialize runtime state
-			loadedScenarios[i].CBState = &CircuitBreakerState{
-				State: "closed",
-			}
-			addScenarioLocked(&loadedScenarios[i])
+	// 2. Config file
+	if opts.ConfigFile != "" {
+		data, err := os.ReadFile(opts.ConfigFile)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", opts.ConfigFile, err)
 		}
-		currentConfig.Scenarios = loadedScenarios
 	}
 
-	// 2. Load Base Config (from env vars)
-
-	// 2. Load Base Config (from env vars)
-	// Apply overrides from environment variables
-	if port := os.Getenv("PORT"); port != "" {
-		currentConfig.Port = port
-	}
-	if tls := os.Getenv("ENABLE_TLS"); tls == "true" {
-		currentConfig.EnableTLS = true
+	// 3. Env vars and flags
+	if err := applySettings(&cfg, os.LookupEnv, opts.Flags); err != nil {
+		return Config{}, err
 	}
-	if cert := os.Getenv("CERT_FILE"); cert != "" {
-		currentConfig.CertFile = cert
+	if opts.ScenariosPath != "" {
+		cfg.ScenariosPath = opts.ScenariosPath
 	}
-	if key := os.Getenv("KEY_FILE"); key != "" {
-		currentConfig.KeyFile = key
-	}
-	if cors := os.Getenv("ENABLE_CORS"); cors != "" {
-		currentConfig.EnableCORS = cors == "true"
-	}
-	if logReq := os.Getenv("LOG_REQUESTS"); logReq != "" {
-		currentConfig.LogRequests = logReq == "true"
-	}
-	if logHead := os.Getenv("LOG_HEADERS"); logHead != "" {
-		currentConfig.LogHeaders = logHead == "true"
-	}
-	if logBody := os.Getenv("LOG_BODY"); logBody != "" {
-		currentConfig.LogBody = logBody == "true"
+	if err := validateConfig(&cfg); err != nil {
+		return Config{}, err
 	}
 
-	// Numeric / Duration Configs
-	if rps := os.Getenv("RATE_LIMIT_RPS"); rps != "" {
-		if val, err := strconv.ParseFloat(rps, 64); err == nil {
-			currentConfig.RateLimitPerS = val
-		}
+	// 4. Scenarios
+	var paths []string
+	if opts.ConfigFile != "" {
+		paths = append(paths, opts.ConfigFile)
 	}
-	if hist := os.Getenv("HISTORY_SIZE"); hist != "" {
-		if val, err := strconv.Atoi(hist); err == nil {
-			currentConfig.HistorySize = val
-		}
+	if cfg.ScenariosPath != "" {
+		paths = append(paths, cfg.ScenariosPath)
 	}
-	if maxBody := os.Getenv("MAX_BODY_SIZE"); maxBody != "" {
-		if val, err := strconv.ParseInt(maxBody, 10, 64); err == nil {
-			currentConfig.MaxBodySize = val
-		}
+	l, err := loadScenarioSources(cfg.StrictScenarios, paths...)
+	if err != nil {
+		return Config{}, err
 	}
-	if delay := os.Getenv("ECHO_DELAY"); delay != "" {
-		if val, err := time.ParseDuration(delay); err == nil {
-			currentConfig.GlobalDelay = val
-		}
+	loaded := l.scenarios
+
+	if cfg.Profile == DefaultProfile {
+		cfg.Profile = ""
 	}
-	if chaos := os.Getenv("ECHO_CHAOS_PROBABILITY"); chaos != "" {
-		if val, err := strconv.ParseFloat(chaos, 64); err == nil {
-			currentConfig.GlobalChaosProbability = val
-		}
+	if cfg.Profile != "" && !hasProfile(loaded, cfg.Profile) {
+		return Config{}, fmt.Errorf("%w %q", ErrUnknownProfile, cfg.Profile)
 	}
 
+	currentConfig = cfg
+	scenarioPaths = paths
+	activeProfile = cfg.Profile
+	replaceResources(l.resources)
+	replaceFileScenariosLocked(loaded, l.files)
+
 	if currentConfig.RateLimitPerS > 0 {
 		rateLimiter = rate.NewLimiter(rate.Limit(currentConfig.RateLimitPerS), int(currentConfig.RateLimitPerS))
 	} else {
 		rateLimiter = nil
 	}
 
-	// Update Hostname if available
-	if h, err := os.Hostname(); err == nil {
-		currentConfig.Hostname = h
-	}
-
 	return currentConfig, nil
 }
 
-// AddScenario adds or updates a scenario in the thread-safe map
-func AddScenario(s *Scenario) {
-	configLock.Lock()
-	defer configLock.Unlock()
-	addScenarioLocked(s)
-}
-
-// addScenarioLocked adds a scenario without locking (caller must hold configLock)
-func addScenarioLocked(s *Scenario) {
-	if s.CBState == nil {
-		s.CBState = &CircuitBreakerState{State: "closed"}
-	}
-	key := s.Path + "_" + s.Method
-
-	// Load existing list or create new
-	if v, ok := scenarios.Load(key); ok {
-		oldList := v.([]*Scenario)
-		// Copy-On-Write: Create a new slice to avoid race conditions with readers
-		// who might be iterating over the old slice.
-		newList := make([]*Scenario, len(oldList)+1)
-		copy(newList, oldList)
-		newList[len(oldList)] = s
-		scenarios.Store(key, newList)
-	} else {
-		scenarios.Store(key, []*Scenario{s})
-	}
-}
-
-// GetScenarios returns a copy of the loaded scenarios map
+// GetScenarios returns the live scenarios map.
+// The map is replaced as a whole on reload, so callers should not hold on to it.
 func GetScenarios() *sync.Map {
-	return &scenarios
+	return scenarios.Load()
 }
 
 // GetRateLimiter returns the global rate limiter
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExportedScenario is a scenario as written by ExportScenarios, optionally with a snapshot
+// of its runtime state. The state is informational and ignored when the file is loaded.
+type ExportedScenario struct {
+	Scenario `yaml:",inline"`
+	State    *ScenarioState `yaml:"state,omitempty" json:"state,omitempty"`
+}
+
+// ScenarioState is a snapshot of a scenario's runtime state
+type ScenarioState struct {
+	Index          int32                       `yaml:"index" json:"index"` // Next step of the sequence, counting each response times times
+	Hits           uint64                      `yaml:"hits" json:"hits"`   // Requests matched
+	CircuitBreaker *CircuitBreakerSnapshot     `yaml:"circuitBreaker,omitempty" json:"circuitBreaker,omitempty"`
+	Sequences      map[string]SequenceSnapshot `yaml:"sequences,omitempty" json:"sequences,omitempty"` // By sequenceKey value
+}
+
+// SequenceSnapshot is the state of the sequence of one sequenceKey value
+type SequenceSnapshot struct {
+	Index          int32                   `yaml:"index" json:"index"`
+	CircuitBreaker *CircuitBreakerSnapshot `yaml:"circuitBreaker,omitempty" json:"circuitBreaker,omitempty"`
+}
+
+// CircuitBreakerSnapshot is a copy of CircuitBreakerState taken under its lock
+type CircuitBreakerSnapshot struct {
+	State          string    `yaml:"state" json:"state"`
+	Failures       int       `yaml:"failures" json:"failures"`
+	Successes      int       `yaml:"successes" json:"successes"`
+	LastFailure    time.Time `yaml:"lastFailure,omitempty" json:"lastFailure,omitempty"`
+	LastTransition time.Time `yaml:"lastTransition,omitempty" json:"lastTransition,omitempty"`
+}
+
+// SnapshotScenario returns a copy of s that is safe to serialize, with the state snapshot
+// attached when withState is set. Body files are written as absolute paths, so the copy
+// loads from any directory.
+func SnapshotScenario(s *Scenario, withState bool) ExportedScenario {
+	e := ExportedScenario{Scenario: s.Definition()}
+	e.Source = ""
+	e.Responses = absBodyFiles(e.Responses)
+
+	if rt := s.Runtime; withState && rt != nil {
+		state := &ScenarioState{
+			Index: atomic.LoadInt32(&rt.Index),
+			Hits:  atomic.LoadUint64(&rt.Hits),
+		}
+		state.CircuitBreaker = snapshotCircuitBreaker(rt.CBState)
+		for _, key := range rt.Sequences.Keys() {
+			seq, ok := rt.Sequences.Lookup(key)
+			if !ok {
+				continue // Reset or evicted since
+			}
+			if state.Sequences == nil {
+				state.Sequences = make(map[string]SequenceSnapshot)
+			}
+			state.Sequences[key] = SequenceSnapshot{
+				Index:          atomic.LoadInt32(&seq.Index),
+				CircuitBreaker: snapshotCircuitBreaker(seq.CBState),
+			}
+		}
+		e.State = state
+	}
+	return e
+}
+
+func snapshotCircuitBreaker(cb *CircuitBreakerState) *CircuitBreakerSnapshot {
+	if cb == nil {
+		return nil
+	}
+	cb.Mutex.Lock()
+	defer cb.Mutex.Unlock()
+	return &CircuitBreakerSnapshot{
+		State:          cb.State,
+		Failures:       cb.Failures,
+		Successes:      cb.Successes,
+		LastFailure:    cb.LastFailure,
+		LastTransition: cb.LastTransition,
+	}
+}
+
+// absBodyFiles returns a copy of responses whose body files are absolute paths
+func absBodyFiles(responses []Response) []Response {
+	out := append([]Response(nil), responses...)
+	for i := range out {
+		if path := out[i].BodyFilePath(); path != "" {
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			out[i].BodyFile, out[i].BodyPath = path, ""
+		}
+	}
+	return out
+}
+
+// exportDocument is the mapping form of an export, used when it has profiles or resources
+type exportDocument struct {
+	Scenarios []ExportedScenario            `yaml:"scenarios"`
+	Profiles  map[string][]ExportedScenario `yaml:"profiles,omitempty"`
+	Resources []Resource                    `yaml:"resources,omitempty"`
+}
+
+// exportedScenarios returns the base scenarios, followed by those added at runtime, and the
+// scenarios of every profile. File scenarios are exported as loaded, so base scenarios an
+// active profile overrides and inactive profiles are included; only live ones carry state.
+func exportedScenarios(withState bool) ([]ExportedScenario, map[string][]ExportedScenario) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	m := scenarios.Load()
+	base := []ExportedScenario{}
+	var profiles map[string][]ExportedScenario
+	for i := range loadedScenarios {
+		def := loadedScenarios[i]
+		if def.Resource != "" {
+			continue // Exported as its resource
+		}
+		if live := findScenarioLocked(m, def.ID); live != nil {
+			def.Runtime = live.Runtime
+		}
+		e := SnapshotScenario(&def, withState)
+		if def.Profile == "" {
+			base = append(base, e)
+			continue
+		}
+		if profiles == nil {
+			profiles = make(map[string][]ExportedScenario)
+		}
+		profiles[def.Profile] = append(profiles[def.Profile], e)
+	}
+
+	fromFile := make(map[*Scenario]bool, len(fileScenarios))
+	for _, s := range fileScenarios {
+		fromFile[s] = true
+	}
+	for _, s := range ListScenarios() {
+		if !fromFile[s] && s.Resource == "" {
+			base = append(base, SnapshotScenario(s, withState))
+		}
+	}
+	return base, profiles
+}
+
+// ExportScenarios serializes the scenario set as a scenario file ("yaml" or "json") that
+// LoadConfig can load again: the base scenarios, every profile as loaded, the scenarios added
+// at runtime and the resources. IDs are kept so a reloaded export keeps its stable IDs.
+// Resources are exported as their declarations rather than their generated scenarios.
+func ExportScenarios(format string, withState bool) ([]byte, error) {
+	exported, profiles := exportedScenarios(withState)
+	var document interface{} = exported
+	resources := Resources()
+	for i := range resources {
+		resources[i].Faults = absBodyFiles(resources[i].Faults)
+	}
+	if len(profiles) > 0 || len(resources) > 0 {
+		document = exportDocument{Scenarios: exported, Profiles: profiles, Resources: resources}
+	}
+
+	switch format {
+	case "", "yaml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(document); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "json":
+		// Encode through YAML so durations are written as strings (e.g. "1s"),
+		// which the scenario loader accepts, instead of nanosecond integers
+		var node yaml.Node
+		if err := node.Encode(document); err != nil {
+			return nil, err
+		}
+		var generic interface{}
+		if err := node.Decode(&generic); err != nil {
+			return nil, err
+		}
+		if generic == nil {
+			generic = []interface{}{}
+		}
+		return json.MarshalIndent(generic, "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported export format %q (use yaml or json)", format)
+	}
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exportScenarios = `
+- id: export-structured
+  path: /export/structured
+  method: POST
+  matches:
+    headers:
+      X-Tenant: acme
+  responses:
+    - status: 200
+      delay: 150ms
+      body:
+        ok: true
+    - status: 503
+      delayRange: 100ms-200ms
+      probability: 0.5
+  circuitBreaker:
+    failureThreshold: 2
+    successThreshold: 1
+    timeout: 5s
+- path: /export/plain
+  method: GET
+  responses:
+    - status: 200
+      body: plain text, not JSON
+`
+
+func TestExportScenarios_RoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(exportScenarios), 0o644))
+	_, err := LoadConfig(file)
+	require.NoError(t, err)
+	AddScenario(&Scenario{Path: "/export/runtime", Method: "GET", Responses: []Response{{Status: 202, Body: JSONBody(`{"a": 1}`)}}})
+
+	for _, format := range []string{"yaml", "json"} {
+		t.Run(format, func(t *testing.T) {
+			data, err := ExportScenarios(format, false)
+			require.NoError(t, err)
+			assert.NotContains(t, string(data), "state")
+
+			exported := filepath.Join(t.TempDir(), "export."+format)
+			require.NoError(t, os.WriteFile(exported, data, 0o644))
+			loaded, _, err := loadScenarios(false, exported)
+			require.NoError(t, err, string(data))
+			require.Len(t, loaded, len(ListScenarios()))
+
+			for i := range loaded {
+				live, ok := GetScenario(loaded[i].ID)
+				require.True(t, ok, "Exported scenario %s should keep its ID", loaded[i].ID)
+				assert.Equal(t, fingerprint(live), fingerprint(&loaded[i]))
+			}
+		})
+	}
+
+	_, err = ExportScenarios("xml", false)
+	assert.Error(t, err)
+}
+
+func TestExportScenarios_ProfilesAndBodyFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "fixtures", "user.json"), `{"name": "Ada"}`)
+	file := filepath.Join(dir, "scenarios.yaml")
+	writeFile(t, file, `
+scenarios:
+  - id: export-users
+    path: /export/users
+    method: GET
+    responses:
+      - status: 200
+        bodyFile: fixtures/user.json
+profiles:
+  degraded:
+    - id: export-users-degraded
+      path: /export/users
+      method: GET
+      responses:
+        - status: 503
+  outage:
+    - id: export-users-outage
+      path: /export/users
+      method: GET
+      responses:
+        - status: 500
+`)
+	_, err := LoadConfig(file)
+	require.NoError(t, err)
+	// Unload the body file before the directory is removed, later exports would fail to load
+	empty := filepath.Join(dir, "empty.yaml")
+	writeFile(t, empty, "[]")
+	t.Cleanup(func() { _, _ = LoadConfig(empty) })
+	require.NoError(t, SetProfile("degraded"))
+
+	data, err := ExportScenarios("yaml", false)
+	require.NoError(t, err)
+
+	// Loaded from another directory, the export keeps the profile structure and body files
+	exported := filepath.Join(t.TempDir(), "export.yaml")
+	require.NoError(t, os.WriteFile(exported, data, 0o644))
+	loaded, _, err := loadScenarios(false, exported)
+	require.NoError(t, err, string(data))
+
+	byID := make(map[string]Scenario)
+	for _, s := range loaded {
+		byID[s.ID] = s
+	}
+	require.Contains(t, byID, "export-users", "Base scenario overridden by the active profile is exported")
+	assert.Empty(t, byID["export-users"].Profile)
+	assert.Equal(t, "degraded", byID["export-users-degraded"].Profile)
+	assert.Equal(t, "outage", byID["export-users-outage"].Profile, "Inactive profiles are exported")
+
+	bodyFile := byID["export-users"].Responses[0].BodyFilePath()
+	assert.True(t, filepath.IsAbs(bodyFile))
+	body, err := os.ReadFile(bodyFile)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "Ada"}`, string(body))
+}
+
+func TestExportScenarios_State(t *testing.T) {
+	s := AddScenario(&Scenario{ID: "export-state", Path: "/export/state", Method: "GET", Responses: []Response{{Status: 200}, {Status: 500}}})
+	s.Runtime.Index = 1
+	s.Runtime.Hits = 7
+	s.Runtime.CBState.State = "open"
+	s.Runtime.Sequences.Get("shard-a").Index = 1
+
+	data, err := ExportScenarios("json", true)
+	require.NoError(t, err)
+
+	// Exports with profiles or resources list the scenarios under "scenarios"
+	var document interface{}
+	require.NoError(t, json.Unmarshal(data, &document))
+	exported, _ := document.([]interface{})
+	if mapping, ok := document.(map[string]interface{}); ok {
+		exported, _ = mapping["scenarios"].([]interface{})
+	}
+	var state map[string]interface{}
+	for _, e := range exported {
+		if e.(map[string]interface{})["id"] == "export-state" {
+			state, _ = e.(map[string]interface{})["state"].(map[string]interface{})
+		}
+	}
+	require.NotNil(t, state)
+	assert.EqualValues(t, 1, state["index"])
+	assert.EqualValues(t, 7, state["hits"])
+	assert.Equal(t, "open", state["circuitBreaker"].(map[string]interface{})["state"])
+	assert.EqualValues(t, 1, state["sequences"].(map[string]interface{})["shard-a"].(map[string]interface{})["index"])
+
+	// State is ignored when the export is loaded again, even in strict mode
+	yamlData, err := ExportScenarios("yaml", true)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(yamlData), "hits: 7"))
+	exported2 := filepath.Join(t.TempDir(), "export.yaml")
+	require.NoError(t, os.WriteFile(exported2, yamlData, 0o644))
+	_, _, err = loadScenarios(true, exported2)
+	assert.NoError(t, err)
+}
+
+func TestAddScenario_GeneratedIDSkipsTakenIDs(t *testing.T) {
+	// An export keeps generated IDs, so the next generated ID may already be declared
+	upcoming := "s" + strconv.FormatUint(atomic.LoadUint64(&scenarioSeq)+1, 10)
+	AddScenario(&Scenario{ID: upcoming, Path: "/export/taken", Method: "GET", Responses: []Response{{Status: 200}}})
+
+	s := AddScenario(&Scenario{Path: "/export/generated", Method: "GET", Responses: []Response{{Status: 200}}})
+	assert.NotEqual(t, upcoming, s.ID)
+	taken, _ := GetScenario(upcoming)
+	assert.Equal(t, "/export/taken", taken.Path)
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// interpolationPattern matches $${ (an escaped reference) and ${...} references.
+// Any other $, including $$, is kept as written.
+var interpolationPattern = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+// interpolate expands references in s:
+//
+//	${VAR}          value of the environment variable VAR, an error if it is unset
+//	${VAR:-default} value of VAR, or default if it is unset or empty
+//	${file:path}    contents of the file (without a trailing newline), relative to dir
+//	$${VAR}         the literal text ${VAR}
+//
+// It returns the expanded string and the files that were read.
+func interpolate(s, dir string, lookupEnv func(string) (string, bool)) (string, []string, error) {
+	var files []string
+	var firstErr error
+	result := interpolationPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		if firstErr != nil {
+			return match
+		}
+
+		ref := match[2 : len(match)-1]
+		if path, ok := strings.CutPrefix(ref, "file:"); ok {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				firstErr = fmt.Errorf("%s: %w", match, err)
+				return match
+			}
+			files = append(files, path)
+			return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+		}
+
+		name, def, hasDefault := strings.Cut(ref, ":-")
+		if name == "" {
+			firstErr = fmt.Errorf("%s: missing variable name", match)
+			return match
+		}
+		if value, ok := lookupEnv(name); ok && (value != "" || !hasDefault) {
+			return value
+		}
+		if hasDefault {
+			return def
+		}
+		firstErr = fmt.Errorf("environment variable %s is not set (use ${%s:-default} for an optional value)", name, name)
+		return match
+	})
+	return result, files, firstErr
+}
+
+// interpolateNode expands references in every scalar of a parsed file, so positions in
+// later problems still point at the original source. Expanded plain scalars are re-resolved,
+// so e.g. "status: ${STATUS}" decodes as an int.
+func (l *scenarioLoader) interpolateNode(file string, n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode && strings.Contains(n.Value, "$") {
+		value, files, err := interpolate(n.Value, filepath.Dir(file), os.LookupEnv)
+		if err != nil {
+			l.problems = append(l.problems, Problem{File: file, Line: n.Line, Column: n.Column, Message: err.Error()})
+			return
+		}
+		if value != n.Value {
+			n.Value = value
+			if n.Style == 0 {
+				n.Tag = ""
+			}
+		}
+		// Referenced files are watched for changes like included files
+		l.files = append(l.files, files...)
+	}
+	for _, child := range n.Content {
+		l.interpolateNode(file, child)
+	}
+}
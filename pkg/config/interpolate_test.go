@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token"), []byte("s3cr3t\n"), 0o600))
+	env := map[string]string{"HOST": "api.internal", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		in       string
+		expected string
+		err      string
+	}{
+		{in: "https://${HOST}/v1", expected: "https://api.internal/v1"},
+		{in: "${PORT:-8080}", expected: "8080"},
+		{in: "${HOST:-fallback}", expected: "api.internal"},
+		{in: "${EMPTY:-fallback}", expected: "fallback"},
+		{in: "[${EMPTY}]", expected: "[]"},
+		{in: "Bearer ${file:token}", expected: "Bearer s3cr3t"},
+		{in: "$${HOST} costs $5", expected: "${HOST} costs $5"},
+		{in: "^\\$$ or $$ alone", expected: "^\\$$ or $$ alone"},
+		{in: "{{.Request.Query.id}}", expected: "{{.Request.Query.id}}"},
+		{in: "${MISSING}", err: "environment variable MISSING is not set"},
+		{in: "${file:missing}", err: "${file:missing}: open"},
+		{in: "${}", err: "missing variable name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, _, err := interpolate(tt.in, dir, lookup)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestLoadScenarios_Interpolation(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "token.txt")
+	writeFile(t, secret, "s3cr3t\n")
+	file := filepath.Join(dir, "scenarios.yaml")
+	writeFile(t, file, `
+- path: /api/${SERVICE}
+  method: GET
+  responses:
+    - status: ${STATUS:-200}
+      headers:
+        Location: https://${UPSTREAM_HOST}/login
+      body:
+        token: ${file:token.txt}
+`)
+	t.Setenv("SERVICE", "users")
+	t.Setenv("UPSTREAM_HOST", "auth.example.com")
+
+	loaded, files, err := loadScenarios(false, file)
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, "/api/users", loaded[0].Path)
+	assert.Equal(t, 200, loaded[0].Responses[0].Status, "Expanded plain scalars should decode as their resolved type")
+	assert.Equal(t, "https://auth.example.com/login", loaded[0].Responses[0].Headers["Location"])
+	assert.JSONEq(t, `{"token": "s3cr3t"}`, string(loaded[0].Responses[0].Body))
+	assert.Contains(t, files, secret, "Secret files should be watched for changes")
+
+	// Unset variables without a default are reported with their position
+	require.NoError(t, os.Unsetenv("UPSTREAM_HOST"))
+	_, _, err = loadScenarios(false, file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), file+":7:19: environment variable UPSTREAM_HOST is not set")
+}
@@ -0,0 +1,321 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scenarioFileExts lists the extensions picked up when loading a scenario directory
+var scenarioFileExts = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// scenarioDocument is the mapping form of a scenario file:
+//
+//	include:
+//	  - common.yaml
+//	scenarios:
+//	  - path: /api/users
+//	    ...
+//	profiles:
+//	  degraded:
+//	    - path: /api/users
+//	      ...
+//	resources:
+//	  - path: /api/orders
+//	    ...
+//
+// A file may also be a plain list of scenarios.
+type scenarioDocument struct {
+	Include   yaml.Node `yaml:"include"`
+	Scenarios yaml.Node `yaml:"scenarios"`
+	Profiles  yaml.Node `yaml:"profiles"`  // Profile name -> scenarios that override the base set
+	Resources yaml.Node `yaml:"resources"` // In-memory REST collections, see Resource
+}
+
+// scenarioLoader loads scenario files, following include directives.
+// Every file is loaded at most once, so include cycles and overlapping includes are harmless.
+type scenarioLoader struct {
+	strict        bool // Reject unknown fields
+	scenarios     []Scenario
+	resources     []Resource
+	files         []string
+	seen          map[string]bool
+	ids           map[string]string // Scenario ID -> file:line where it was first declared
+	resourcePaths map[string]string // Resource path -> file:line where it was first declared
+	problems      []Problem
+}
+
+// loadScenarios loads scenarios from each path, which may be a single file or a directory
+// whose *.yaml, *.yml and *.json files are loaded in lexical order. Missing paths are skipped
+// with a warning. It returns the scenarios, including those generated for resources, together
+// with every file that was read, including included files. Validation problems across all
+// files are reported together in a *ValidationError. In strict mode, unknown fields are
+// reported as problems too.
+func loadScenarios(strict bool, paths ...string) ([]Scenario, []string, error) {
+	l, err := loadScenarioSources(strict, paths...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l.scenarios, l.files, nil
+}
+
+// loadScenarioSources is loadScenarios, also returning the declared resources
+func loadScenarioSources(strict bool, paths ...string) (*scenarioLoader, error) {
+	l := &scenarioLoader{strict: strict, seen: make(map[string]bool), ids: make(map[string]string), resourcePaths: make(map[string]string)}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			log.Printf("Warning: Failed to read %s, running without its scenarios: %v", path, err)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		files := []string{path}
+		if info.IsDir() {
+			if files, err = scenarioDirFiles(path); err != nil {
+				return nil, err
+			}
+		}
+		for _, file := range files {
+			if err := l.loadFile(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(l.problems) > 0 {
+		return nil, &ValidationError{Problems: l.problems}
+	}
+	return l, nil
+}
+
+// scenarioDirFiles lists the scenario files directly inside dir
+func scenarioDirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !scenarioFileExts[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	return files, nil
+}
+
+// loadFile parses a single scenario file. Included files are loaded before the file's own scenarios.
+func (l *scenarioLoader) loadFile(file string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	if l.seen[abs] {
+		return nil
+	}
+	l.seen[abs] = true
+	l.files = append(l.files, file)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	if len(root.Content) == 0 {
+		// Empty file
+		return nil
+	}
+
+	problems := len(l.problems)
+	l.interpolateNode(file, &root)
+	if len(l.problems) > problems {
+		// Unexpanded references would only cause confusing decode errors
+		return nil
+	}
+
+	items := root.Content[0]
+	switch items.Kind {
+	case yaml.SequenceNode:
+		// Plain list of scenarios
+		return l.loadItems(file, items, "")
+	case yaml.MappingNode:
+		if l.strict {
+			l.problems = append(l.problems, unknownDocumentKeys(file, items)...)
+		}
+		var doc scenarioDocument
+		if err := items.Decode(&doc); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if err := l.loadIncludes(file, &doc.Include); err != nil {
+			return err
+		}
+		if err := l.loadItems(file, &doc.Scenarios, ""); err != nil {
+			return err
+		}
+		if err := l.loadResources(file, &doc.Resources); err != nil {
+			return err
+		}
+		return l.loadProfiles(file, &doc.Profiles)
+	default:
+		return fmt.Errorf("%s:%d: expected a list of scenarios or a mapping with include/scenarios", file, items.Line)
+	}
+}
+
+// loadProfiles loads the scenarios of each named profile
+func (l *scenarioLoader) loadProfiles(file string, profiles *yaml.Node) error {
+	if profiles.Kind == 0 {
+		return nil
+	}
+	if profiles.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s:%d: profiles must be a mapping of profile names to scenarios", file, profiles.Line)
+	}
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		name := profiles.Content[i]
+		switch {
+		case name.Value == DefaultProfile:
+			l.problems = append(l.problems, Problem{File: file, Line: name.Line, Column: name.Column,
+				Message: fmt.Sprintf("profile name %q is reserved for the base scenarios", DefaultProfile)})
+			continue
+		case !validID.MatchString(name.Value):
+			l.problems = append(l.problems, Problem{File: file, Line: name.Line, Column: name.Column,
+				Message: fmt.Sprintf("profile name %q may only contain letters, digits, '.', '_', '~' and '-'", name.Value)})
+			continue
+		}
+		if err := l.loadItems(file, profiles.Content[i+1], name.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadItems decodes and validates a list of scenarios belonging to profile (empty for the base set)
+func (l *scenarioLoader) loadItems(file string, items *yaml.Node, profile string) error {
+	if items.Kind == 0 {
+		// No scenarios key
+		return nil
+	}
+	if items.Kind != yaml.SequenceNode {
+		return fmt.Errorf("%s:%d: scenarios must be a list", file, items.Line)
+	}
+
+	for _, item := range items.Content {
+		var s Scenario
+		if err := item.Decode(&s); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if l.strict {
+			// Exports may carry runtime state, see ExportedScenario
+			l.problems = append(l.problems, unknownFields(file, item, reflect.TypeOf(ExportedScenario{}), "")...)
+		}
+		resolveBodyFiles(file, s.Responses)
+		l.problems = append(l.problems, ValidateScenario(&s, file, item)...)
+		if s.ID != "" {
+			location := fmt.Sprintf("%s:%d", file, item.Line)
+			if first, ok := l.ids[s.ID]; ok {
+				idNode := field(item, "id")
+				l.problems = append(l.problems, Problem{File: file, Line: idNode.Line, Column: idNode.Column,
+					Message: fmt.Sprintf("id %q is already used by the scenario at %s", s.ID, first)})
+			} else {
+				l.ids[s.ID] = location
+			}
+		}
+		s.Source = file
+		s.Profile = profile
+		l.scenarios = append(l.scenarios, s)
+	}
+	return nil
+}
+
+// loadResources decodes and validates a list of resources, adding the scenarios that serve them
+func (l *scenarioLoader) loadResources(file string, items *yaml.Node) error {
+	if items.Kind == 0 {
+		return nil
+	}
+	if items.Kind != yaml.SequenceNode {
+		return fmt.Errorf("%s:%d: resources must be a list", file, items.Line)
+	}
+
+	for _, item := range items.Content {
+		var r Resource
+		if err := item.Decode(&r); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if l.strict {
+			l.problems = append(l.problems, unknownFields(file, item, reflect.TypeOf(r), "")...)
+		}
+		resolveBodyFiles(file, r.Faults)
+		l.problems = append(l.problems, ValidateResource(&r, file, item)...)
+		if first, ok := l.resourcePaths[r.Path]; ok && r.Path != "" {
+			pathNode := field(item, "path")
+			l.problems = append(l.problems, Problem{File: file, Line: pathNode.Line, Column: pathNode.Column,
+				Message: fmt.Sprintf("resource %q is already declared at %s", r.Path, first)})
+			continue
+		}
+		l.resourcePaths[r.Path] = fmt.Sprintf("%s:%d", file, item.Line)
+		l.resources = append(l.resources, r)
+		for _, s := range r.scenarios() {
+			s.Source = file
+			l.scenarios = append(l.scenarios, s)
+		}
+	}
+	return nil
+}
+
+// resolveBodyFiles resolves relative body files against the directory of the file declaring them
+func resolveBodyFiles(file string, responses []Response) {
+	for i := range responses {
+		if bodyFile := responses[i].BodyFile; bodyFile != "" && !filepath.IsAbs(bodyFile) {
+			responses[i].BodyPath = filepath.Join(filepath.Dir(file), bodyFile)
+		}
+	}
+}
+
+// loadIncludes loads the files referenced by an include directive.
+// Paths are relative to the including file and may contain glob patterns.
+func (l *scenarioLoader) loadIncludes(file string, include *yaml.Node) error {
+	var entries []*yaml.Node
+	switch include.Kind {
+	case 0:
+		return nil
+	case yaml.ScalarNode:
+		entries = []*yaml.Node{include}
+	case yaml.SequenceNode:
+		entries = include.Content
+	default:
+		return fmt.Errorf("%s:%d: include must be a path or a list of paths", file, include.Line)
+	}
+
+	for _, entry := range entries {
+		if entry.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s:%d: include entries must be paths", file, entry.Line)
+		}
+		pattern := entry.Value
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid include %q: %w", file, entry.Line, entry.Value, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("%s:%d: include %q matched no files", file, entry.Line, entry.Value)
+		}
+		for _, match := range matches {
+			if err := l.loadFile(match); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestLoadScenarios_Directory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "payments.yaml"), `
+include:
+  - shared/*.yaml
+scenarios:
+  - path: /payments
+    method: POST
+    responses:
+      - status: 201
+`)
+	writeFile(t, filepath.Join(dir, "users.json"), `[{"path": "/users", "method": "GET", "responses": [{"status": 200}]}]`)
+	writeFile(t, filepath.Join(dir, "shared", "auth.yaml"), `
+- path: /auth
+  method: POST
+  responses:
+    - status: 401
+`)
+	writeFile(t, filepath.Join(dir, "README.md"), "not a scenario file")
+
+	scenarios, files, err := loadScenarios(false, dir)
+	require.NoError(t, err)
+
+	require.Len(t, scenarios, 3)
+	assert.Equal(t, "/auth", scenarios[0].Path, "Included scenarios load before the including file's own")
+	assert.Equal(t, filepath.Join(dir, "shared", "auth.yaml"), scenarios[0].Source)
+	assert.Equal(t, "/payments", scenarios[1].Path)
+	assert.Equal(t, filepath.Join(dir, "payments.yaml"), scenarios[1].Source)
+	assert.Equal(t, "/users", scenarios[2].Path)
+	assert.Equal(t, filepath.Join(dir, "users.json"), scenarios[2].Source)
+	assert.Len(t, files, 3)
+}
+
+func TestLoadScenarios_IncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), "include: b.yaml\nscenarios:\n  - path: /a\n    method: GET\n    responses:\n      - status: 200\n")
+	writeFile(t, filepath.Join(dir, "b.yaml"), "include: a.yaml\nscenarios:\n  - path: /b\n    method: GET\n    responses:\n      - status: 200\n")
+
+	scenarios, _, err := loadScenarios(false, dir)
+	require.NoError(t, err)
+	assert.Len(t, scenarios, 2, "Each file should be loaded once")
+}
+
+func TestLoadScenarios_ErrorsNameFileAndLine(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "broken.yaml")
+	writeFile(t, file, `
+- path: /ok
+  method: GET
+  responses:
+    - status: 200
+- path: /missing-responses
+  method: GET
+`)
+	_, _, err := loadScenarios(false, dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), file+":6:")
+
+	writeFile(t, file, "include: nothing-here.yaml\n")
+	_, _, err = loadScenarios(false, dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), file+":1: include \"nothing-here.yaml\" matched no files")
+}
@@ -0,0 +1,434 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StringMatcher matches a header or query parameter. A plain string is shorthand for equals:
+//
+//	X-Tenant: acme
+//	Authorization:
+//	  prefix: Bearer expired-
+//	X-Debug:
+//	  absent: true
+//
+// All operators that are set must hold. Operators on values succeed if any value of a
+// multi-valued header or query parameter satisfies them.
+type StringMatcher struct {
+	Equals          string   `yaml:"equals,omitempty" json:"equals,omitempty"`
+	Contains        string   `yaml:"contains,omitempty" json:"contains,omitempty"`
+	Prefix          string   `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	Suffix          string   `yaml:"suffix,omitempty" json:"suffix,omitempty"`
+	Regex           string   `yaml:"regex,omitempty" json:"regex,omitempty"`
+	In              []string `yaml:"in,omitempty" json:"in,omitempty"`
+	Present         bool     `yaml:"present,omitempty" json:"present,omitempty"`
+	Absent          bool     `yaml:"absent,omitempty" json:"absent,omitempty"`
+	CaseInsensitive bool     `yaml:"caseInsensitive,omitempty" json:"caseInsensitive,omitempty"` // Applies to equals, contains, prefix, suffix, in and regex
+}
+
+// stringMatcherFields has the same fields as StringMatcher without its (un)marshal methods
+type stringMatcherFields StringMatcher
+
+// HasValueOperators reports whether any operator inspects the value, rather than just its presence
+func (m *StringMatcher) HasValueOperators() bool {
+	return m.Equals != "" || m.Contains != "" || m.Prefix != "" || m.Suffix != "" || m.Regex != "" || len(m.In) > 0
+}
+
+// IsZero reports whether no operator is set
+func (m StringMatcher) IsZero() bool {
+	return !m.Present && !m.Absent && !m.HasValueOperators()
+}
+
+// RegexPattern returns the regular expression the regex operator is evaluated with
+func (m *StringMatcher) RegexPattern() string {
+	if m.CaseInsensitive {
+		return "(?i)" + m.Regex
+	}
+	return m.Regex
+}
+
+// isShorthand reports whether m can be written as a plain string
+func (m StringMatcher) isShorthand() bool {
+	return m.Contains == "" && m.Prefix == "" && m.Suffix == "" && m.Regex == "" && len(m.In) == 0 &&
+		!m.Present && !m.Absent && !m.CaseInsensitive
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (m *StringMatcher) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*m = StringMatcher{Equals: value.Value}
+		return nil
+	}
+	return value.Decode((*stringMatcherFields)(m))
+}
+
+// MarshalYAML implements the yaml.Marshaler interface
+func (m StringMatcher) MarshalYAML() (interface{}, error) {
+	if m.isShorthand() {
+		return m.Equals, nil
+	}
+	return stringMatcherFields(m), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (m *StringMatcher) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*m = StringMatcher{Equals: s}
+		return nil
+	}
+	return json.Unmarshal(data, (*stringMatcherFields)(m))
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (m StringMatcher) MarshalJSON() ([]byte, error) {
+	if m.isShorthand() {
+		return json.Marshal(m.Equals)
+	}
+	return json.Marshal(stringMatcherFields(m))
+}
+
+// JSONPathMatcher matches the values a JSONPath expression selects from a JSON request body:
+//
+//	jsonBody:
+//	  - path: $.items[*].sku
+//	    equals: OUT-OF-STOCK
+//	  - path: $.total
+//	    gte: 100
+//
+// All operators that are set must hold. Operators on values succeed if any selected
+// value satisfies them.
+type JSONPathMatcher struct {
+	Path     string    `yaml:"path" json:"path"`
+	Equals   JSONValue `yaml:"equals,omitempty" json:"equals,omitempty"`     // Any JSON value, compared semantically
+	Contains JSONValue `yaml:"contains,omitempty" json:"contains,omitempty"` // An array element, or a substring of a string
+	Regex    string    `yaml:"regex,omitempty" json:"regex,omitempty"`       // Matched against strings, or the JSON text of other values
+	Gt       Number    `yaml:"gt,omitempty" json:"gt,omitempty"`
+	Gte      Number    `yaml:"gte,omitempty" json:"gte,omitempty"`
+	Lt       Number    `yaml:"lt,omitempty" json:"lt,omitempty"`
+	Lte      Number    `yaml:"lte,omitempty" json:"lte,omitempty"`
+	Exists   bool      `yaml:"exists,omitempty" json:"exists,omitempty"`
+	Absent   bool      `yaml:"absent,omitempty" json:"absent,omitempty"`
+}
+
+// HasValueOperators reports whether any operator inspects the selected values, rather than just their presence
+func (m *JSONPathMatcher) HasValueOperators() bool {
+	return len(m.Equals) > 0 || len(m.Contains) > 0 || m.Regex != "" || m.Gt != "" || m.Gte != "" || m.Lt != "" || m.Lte != ""
+}
+
+// JSONValue holds any JSON value in its encoded form. Unlike JSONBody, strings are always
+// JSON strings, so "5" and 5 stay different.
+type JSONValue json.RawMessage
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (j *JSONValue) UnmarshalYAML(value *yaml.Node) error {
+	var obj interface{}
+	if err := value.Decode(&obj); err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	*j = JSONValue(bytes)
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface
+func (j JSONValue) MarshalYAML() (interface{}, error) {
+	var obj interface{}
+	if err := json.Unmarshal(j, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (j *JSONValue) UnmarshalJSON(data []byte) error {
+	*j = append(JSONValue(nil), data...)
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (j JSONValue) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return json.RawMessage(j).MarshalJSON()
+}
+
+// Number is a numeric operand kept in its literal form, so that 0 can be told apart from unset
+type Number string
+
+// Float returns the value of n
+func (n Number) Float() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// MarshalYAML implements the yaml.Marshaler interface, writing n as a plain number
+func (n Number) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: string(n)}, nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, accepting numbers and numeric strings
+func (n *Number) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*n = Number(s)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return err
+	}
+	*n = Number(num)
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (n Number) MarshalJSON() ([]byte, error) {
+	if _, err := n.Float(); err != nil {
+		return json.Marshal(string(n))
+	}
+	return []byte(n), nil
+}
+
+// BodyMatcher matches the raw request body. All operators that are set must hold:
+//
+//	body:
+//	  contains: '"status":"pending"'
+//	  notContains: test-card
+//
+// For backward compatibility, a plain string is a contains check, unless it is written as
+// /pattern/ or regex:pattern, which is a regular expression.
+type BodyMatcher struct {
+	Equals      string `yaml:"equals,omitempty" json:"equals,omitempty"`
+	Contains    string `yaml:"contains,omitempty" json:"contains,omitempty"`
+	NotContains string `yaml:"notContains,omitempty" json:"notContains,omitempty"`
+	Regex       string `yaml:"regex,omitempty" json:"regex,omitempty"`
+}
+
+// bodyMatcherFields has the same fields as BodyMatcher without its (un)marshal methods
+type bodyMatcherFields BodyMatcher
+
+// IsZero reports whether no body operator is set
+func (m BodyMatcher) IsZero() bool {
+	return m == BodyMatcher{}
+}
+
+// parseBodyShorthand returns the matcher for the legacy string form of matches.body
+func parseBodyShorthand(s string) BodyMatcher {
+	switch {
+	case strings.HasPrefix(s, "regex:"):
+		return BodyMatcher{Regex: strings.TrimPrefix(s, "regex:")}
+	case len(s) > 2 && s[0] == '/' && s[len(s)-1] == '/':
+		return BodyMatcher{Regex: s[1 : len(s)-1]}
+	default:
+		return BodyMatcher{Contains: s}
+	}
+}
+
+// isBodyOperatorMapping reports whether node is the operator form of BodyMatcher rather than
+// a legacy structured body, judged by its first key
+func isBodyOperatorMapping(node *yaml.Node) bool {
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		return false
+	}
+	switch node.Content[0].Value {
+	case "equals", "contains", "notContains", "regex":
+		return true
+	}
+	return false
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface. Besides the operator mapping and
+// the string shorthand, other structured values are matched as contained compact JSON,
+// as they were before body operators existed.
+func (m *BodyMatcher) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*m = parseBodyShorthand(value.Value)
+		return nil
+	}
+	if isBodyOperatorMapping(value) || (value.Kind == yaml.MappingNode && len(value.Content) == 0) {
+		*m = BodyMatcher{}
+		return value.Decode((*bodyMatcherFields)(m))
+	}
+	var legacy JSONBody
+	if err := value.Decode(&legacy); err != nil {
+		return err
+	}
+	*m = BodyMatcher{Contains: string(legacy)}
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface
+func (m BodyMatcher) MarshalYAML() (interface{}, error) {
+	return bodyMatcherFields(m), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, accepting the same forms as UnmarshalYAML
+func (m *BodyMatcher) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*m = parseBodyShorthand(s)
+		return nil
+	}
+	// JSON is YAML, so the structured forms are handled by UnmarshalYAML
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) == 1 {
+		return m.UnmarshalYAML(node.Content[0])
+	}
+	*m = BodyMatcher{}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (m BodyMatcher) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bodyMatcherFields(m))
+}
+
+// PartMatcher matches the parts of a multipart/form-data body with a given field name:
+//
+//	multipart:
+//	  - name: avatar
+//	    filename: {suffix: .png}
+//	    maxSize: 1048576
+//
+// It succeeds if any part with that name satisfies every operator that is set. With no
+// operators, a part with that name must be present.
+type PartMatcher struct {
+	Name        string        `yaml:"name" json:"name"`
+	Filename    StringMatcher `yaml:"filename,omitempty" json:"filename,omitzero"`       // Absent for parts that are not files
+	ContentType StringMatcher `yaml:"contentType,omitempty" json:"contentType,omitzero"` // Media type of the part, without parameters
+	MinSize     int64         `yaml:"minSize,omitempty" json:"minSize,omitempty"`        // Size of the part content in bytes
+	MaxSize     int64         `yaml:"maxSize,omitempty" json:"maxSize,omitempty"`
+	Body        BodyMatcher   `yaml:"body,omitempty" json:"body,omitzero"` // Content of the part
+	Absent      bool          `yaml:"absent,omitempty" json:"absent,omitempty"`
+}
+
+// HasOperators reports whether any operator inspects the parts, rather than just their presence
+func (m *PartMatcher) HasOperators() bool {
+	return !m.Filename.IsZero() || !m.ContentType.IsZero() || m.MinSize != 0 || m.MaxSize != 0 || !m.Body.IsZero()
+}
+
+// GraphQLOperationTypes are the operation types a GraphQLMatcher can require
+var GraphQLOperationTypes = []string{"query", "mutation", "subscription"}
+
+// GraphQLMatcher matches a GraphQL request, sent as a JSON POST body, an application/graphql
+// body or GET query parameters:
+//
+//	graphql:
+//	  operationName: GetHero
+//	  operationType: query
+//	  variables:
+//	    - path: $.episode
+//	      equals: JEDI
+//
+// Without an operationName in the request, the name of the document's only operation is
+// used. Variables paths are relative to the variables object.
+type GraphQLMatcher struct {
+	OperationName StringMatcher     `yaml:"operationName,omitempty" json:"operationName,omitzero"`
+	OperationType string            `yaml:"operationType,omitempty" json:"operationType,omitempty"` // query, mutation or subscription
+	Variables     []JSONPathMatcher `yaml:"variables,omitempty" json:"variables,omitempty"`
+}
+
+// IsZero reports whether no GraphQL operator is set
+func (m GraphQLMatcher) IsZero() bool {
+	return m.OperationName.IsZero() && m.OperationType == "" && len(m.Variables) == 0
+}
+
+// Count returns the number of GraphQL matchers
+func (m *GraphQLMatcher) Count() int {
+	count := len(m.Variables)
+	if !m.OperationName.IsZero() {
+		count++
+	}
+	if m.OperationType != "" {
+		count++
+	}
+	return count
+}
+
+// regexCache holds compiled matcher patterns. Validation compiles every pattern when a
+// scenario is loaded, so requests only compile one again after it was evicted.
+var regexCache = NewCompileCache(regexp.Compile)
+
+// CompileRegex compiles a matcher pattern, caching the result
+func CompileRegex(pattern string) (*regexp.Regexp, error) {
+	return regexCache.Get(pattern)
+}
+
+// ParseClientIP parses an entry of matches.clientIp, either a single address or a CIDR range
+func ParseClientIP(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// MatchList is a list of match blocks that may also be written as a single block
+type MatchList []MatchConfig
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (l *MatchList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var m MatchConfig
+		if err := value.Decode(&m); err != nil {
+			return err
+		}
+		*l = MatchList{m}
+		return nil
+	}
+	return value.Decode((*[]MatchConfig)(l))
+}
+
+// MarshalYAML implements the yaml.Marshaler interface
+func (l MatchList) MarshalYAML() (interface{}, error) {
+	if len(l) == 1 {
+		return l[0], nil
+	}
+	return []MatchConfig(l), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (l *MatchList) UnmarshalJSON(data []byte) error {
+	switch trimmed := bytes.TrimSpace(data); {
+	case string(trimmed) == "null":
+		return nil
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		return json.Unmarshal(data, (*[]MatchConfig)(l))
+	}
+	var m MatchConfig
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*l = MatchList{m}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (l MatchList) MarshalJSON() ([]byte, error) {
+	if len(l) == 1 {
+		return json.Marshal(l[0])
+	}
+	return json.Marshal([]MatchConfig(l))
+}
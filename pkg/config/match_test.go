@@ -0,0 +1,144 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestStringMatcher_Unmarshal(t *testing.T) {
+	var m MatchConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+headers:
+  X-Tenant: acme
+  Authorization:
+    prefix: Bearer expired-
+  Region:
+    in: [eu, us]
+    caseInsensitive: true
+`), &m))
+	assert.Equal(t, StringMatcher{Equals: "acme"}, m.Headers["X-Tenant"])
+	assert.Equal(t, StringMatcher{Prefix: "Bearer expired-"}, m.Headers["Authorization"])
+	assert.Equal(t, StringMatcher{In: []string{"eu", "us"}, CaseInsensitive: true}, m.Headers["Region"])
+
+	var fromJSON MatchConfig
+	require.NoError(t, json.Unmarshal([]byte(`{"query": {"a": "b", "c": {"absent": true}}}`), &fromJSON))
+	assert.Equal(t, StringMatcher{Equals: "b"}, fromJSON.Query["a"])
+	assert.Equal(t, StringMatcher{Absent: true}, fromJSON.Query["c"])
+}
+
+func TestStringMatcher_Marshal(t *testing.T) {
+	m := map[string]StringMatcher{"a": {Equals: "b"}, "c": {Present: true}}
+
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a": "b", "c": {"present": true}}`, string(data), "Plain equality is written in shorthand")
+
+	out, err := yaml.Marshal(m)
+	require.NoError(t, err)
+	assert.Equal(t, "a: b\nc:\n    present: true\n", string(out))
+}
+
+func TestJSONPathMatcher_Unmarshal(t *testing.T) {
+	var m MatchConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+jsonBody:
+  - path: $.sku
+    equals: "5"
+  - path: $.qty
+    equals: 5
+    gte: 0
+  - path: $.tags
+    contains: {id: 1}
+equalToJson:
+  id: 7
+ignoreExtraFields: true
+`), &m))
+	require.Len(t, m.JSONBody, 3)
+	assert.Equal(t, JSONValue(`"5"`), m.JSONBody[0].Equals, "Strings stay strings")
+	assert.Equal(t, JSONValue(`5`), m.JSONBody[1].Equals)
+	assert.Equal(t, Number("0"), m.JSONBody[1].Gte, "Zero is kept")
+	assert.Equal(t, JSONValue(`{"id":1}`), m.JSONBody[2].Contains)
+	assert.Equal(t, JSONValue(`{"id":7}`), m.EqualToJSON)
+	assert.True(t, m.IgnoreExtraFields)
+
+	var fromJSON JSONPathMatcher
+	require.NoError(t, json.Unmarshal([]byte(`{"path": "$.a", "equals": [1, "x"], "gt": 1.5, "lt": "10"}`), &fromJSON))
+	assert.Equal(t, JSONValue(`[1, "x"]`), fromJSON.Equals)
+	assert.Equal(t, Number("1.5"), fromJSON.Gt)
+	assert.Equal(t, Number("10"), fromJSON.Lt)
+}
+
+func TestJSONPathMatcher_Marshal(t *testing.T) {
+	m := JSONPathMatcher{Path: "$.a", Equals: JSONValue(`"5"`), Gte: "0"}
+
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"path": "$.a", "equals": "5", "gte": 0}`, string(data))
+
+	out, err := yaml.Marshal(m)
+	require.NoError(t, err)
+	var back JSONPathMatcher
+	require.NoError(t, yaml.Unmarshal(out, &back))
+	assert.Equal(t, m, back, "YAML round trip keeps types")
+}
+
+func TestBodyMatcher_Unmarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected BodyMatcher
+	}{
+		{"plain string contains", `body: pending`, BodyMatcher{Contains: "pending"}},
+		{"slashes are a regex", `body: /^START.*/`, BodyMatcher{Regex: "^START.*"}},
+		{"regex prefix", `body: "regex:^START.*"`, BodyMatcher{Regex: "^START.*"}},
+		{"operators", "body:\n  contains: a\n  notContains: b", BodyMatcher{Contains: "a", NotContains: "b"}},
+		{"legacy structured body", "body:\n  status: pending", BodyMatcher{Contains: `{"status":"pending"}`}},
+		{"empty mapping", "body: {}", BodyMatcher{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m MatchConfig
+			require.NoError(t, yaml.Unmarshal([]byte(tt.yaml), &m))
+			assert.Equal(t, tt.expected, m.Body)
+		})
+	}
+
+	var fromJSON MatchConfig
+	require.NoError(t, json.Unmarshal([]byte(`{"body": "/x+/"}`), &fromJSON))
+	assert.Equal(t, BodyMatcher{Regex: "x+"}, fromJSON.Body)
+	require.NoError(t, json.Unmarshal([]byte(`{"body": {"equals": "ok"}}`), &fromJSON))
+	assert.Equal(t, BodyMatcher{Equals: "ok"}, fromJSON.Body)
+
+	data, err := json.Marshal(fromJSON.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"equals": "ok"}`, string(data))
+}
+
+func TestMatchList(t *testing.T) {
+	var m MatchConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+anyOf:
+  - headers: {X-A: "1"}
+  - query: {b: "2"}
+not:
+  headers: {X-Canary: {present: true}}
+`), &m))
+	require.Len(t, m.AnyOf, 2)
+	require.Len(t, m.Not, 1, "A single block is a list of one")
+	assert.Equal(t, StringMatcher{Present: true}, m.Not[0].Headers["X-Canary"])
+	assert.Equal(t, 2, m.Count(), "anyOf counts its least specific block, not one per block")
+
+	data, err := json.Marshal(m.Not)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"headers": {"X-Canary": {"present": true}}}`, string(data))
+
+	var fromJSON MatchConfig
+	require.NoError(t, json.Unmarshal([]byte(`{"not": [{"query": {"a": "1"}}, {"query": {"b": "2"}}]}`), &fromJSON))
+	assert.Len(t, fromJSON.Not, 2)
+	require.NoError(t, json.Unmarshal([]byte(`{"not": {"query": {"a": "1"}}}`), &fromJSON))
+	assert.Len(t, fromJSON.Not, 1)
+}
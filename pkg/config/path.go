@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CatchAllVar is the path variable holding the segments matched by a trailing **
+const CatchAllVar = "**"
+
+// PathTemplate is a compiled scenario path. Paths may contain:
+//
+//	{name}          a variable matching one segment
+//	{name:pattern}  a variable matching a regular expression, e.g. {id:[0-9]+}
+//	*               any single segment
+//	**              any number of trailing segments, captured as the ** variable
+//
+// Variables may be part of a segment, e.g. /files/{name}.json. A trailing slash is
+// optional in both the template and the request path.
+type PathTemplate struct {
+	re      *regexp.Regexp
+	vars    []string // Variable names, by capture group v0, v1, ...
+	groups  []int    // Submatch index of each variable
+	literal int      // Number of segments without variables or wildcards
+}
+
+// ParsePathTemplate compiles a scenario path
+func ParsePathTemplate(path string) (*PathTemplate, error) {
+	t := &PathTemplate{}
+	trimmed := strings.TrimSuffix(path, "/")
+	segments := strings.Split(strings.TrimPrefix(trimmed, "/"), "/")
+	if trimmed == "" {
+		segments = nil
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for i, segment := range segments {
+		switch {
+		case segment == "**":
+			if i != len(segments)-1 {
+				return nil, fmt.Errorf("** must be the last segment of %q", path)
+			}
+			fmt.Fprintf(&pattern, "(?:/(?P<v%d>.*))?", len(t.vars))
+			t.vars = append(t.vars, CatchAllVar)
+			continue
+		case segment == "*":
+			pattern.WriteString("/[^/]+")
+			continue
+		}
+
+		pattern.WriteString("/")
+		hasVar := false
+		for rest := segment; rest != ""; {
+			open := strings.IndexByte(rest, '{')
+			if open < 0 {
+				pattern.WriteString(regexp.QuoteMeta(rest))
+				break
+			}
+			pattern.WriteString(regexp.QuoteMeta(rest[:open]))
+			end := closingBrace(rest, open)
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed { in %q", path)
+			}
+			name, varPattern, hasPattern := strings.Cut(rest[open+1:end], ":")
+			if name == "" {
+				return nil, fmt.Errorf("variable without a name in %q", path)
+			}
+			if !hasPattern {
+				varPattern = "[^/]+"
+			} else if _, err := regexp.Compile(varPattern); err != nil {
+				return nil, fmt.Errorf("variable %q in %q has an invalid pattern: %v", name, path, err)
+			}
+			fmt.Fprintf(&pattern, "(?P<v%d>%s)", len(t.vars), varPattern)
+			t.vars = append(t.vars, name)
+			hasVar = true
+			rest = rest[end+1:]
+		}
+		if !hasVar && segment != "" {
+			t.literal++
+		}
+	}
+	pattern.WriteString("/?$")
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %v", path, err)
+	}
+	t.re = re
+	for i := range t.vars {
+		t.groups = append(t.groups, re.SubexpIndex(fmt.Sprintf("v%d", i)))
+	}
+	return t, nil
+}
+
+// closingBrace returns the index of the } that closes the { at s[open], allowing nested
+// braces such as the quantifier in {id:[0-9]{3}}
+func closingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Match reports whether path matches the template and returns the captured variables
+func (t *PathTemplate) Match(path string) (map[string]string, bool) {
+	m := t.re.FindStringSubmatch(path)
+	if m == nil {
+		return nil, false
+	}
+	if len(t.vars) == 0 {
+		return nil, true
+	}
+	vars := make(map[string]string, len(t.vars))
+	for i, name := range t.vars {
+		vars[name] = m[t.groups[i]]
+	}
+	return vars, true
+}
+
+// Vars returns the names of the template's variables, in order
+func (t *PathTemplate) Vars() []string {
+	return t.vars
+}
+
+// LiteralSegments returns the number of segments without variables or wildcards
+func (t *PathTemplate) LiteralSegments() int {
+	return t.literal
+}
+
+// pathTemplateCache holds compiled scenario paths, so each is compiled once while in use
+var pathTemplateCache = NewCompileCache(ParsePathTemplate)
+
+// CompilePathTemplate compiles a scenario path, caching the result
+func CompilePathTemplate(path string) (*PathTemplate, error) {
+	return pathTemplateCache.Get(path)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathTemplate_Match(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		matches  bool
+		vars     map[string]string
+	}{
+		{"/users", "/users", true, nil},
+		{"/users", "/users/", true, nil},
+		{"/users/", "/users", true, nil},
+		{"/users", "/users/42", false, nil},
+		{"/", "/", true, nil},
+		{"/", "/users", false, nil},
+		{"/users/{id}", "/users/42", true, map[string]string{"id": "42"}},
+		{"/users/{id}", "/users/42/", true, map[string]string{"id": "42"}},
+		{"/users/{id}", "/users", false, nil},
+		{"/users/{id}", "/users/42/orders", false, nil},
+		{"/users/{id:[0-9]+}", "/users/42", true, map[string]string{"id": "42"}},
+		{"/users/{id:[0-9]+}", "/users/bob", false, nil},
+		{"/codes/{code:[A-Z]{3}}", "/codes/ABC", true, map[string]string{"code": "ABC"}},
+		{"/codes/{code:[A-Z]{3}}", "/codes/ABCD", false, nil},
+		{"/files/{name}.json", "/files/report.json", true, map[string]string{"name": "report"}},
+		{"/files/{name}.json", "/files/report.xml", false, nil},
+		{"/users/{user-id}/orders/{order_id}", "/users/7/orders/9", true, map[string]string{"user-id": "7", "order_id": "9"}},
+		{"/api/*/health", "/api/orders/health", true, nil},
+		{"/api/*/health", "/api/orders/v1/health", false, nil},
+		{"/api/*/health", "/api//health", false, nil},
+		{"/static/**", "/static/css/site.css", true, map[string]string{"**": "css/site.css"}},
+		{"/static/**", "/static", true, map[string]string{"**": ""}},
+		{"/static/**", "/static/", true, map[string]string{"**": ""}},
+		{"/static/**", "/assets/site.css", false, nil},
+		{"/tenants/{tenant}/**", "/tenants/acme/a/b", true, map[string]string{"tenant": "acme", "**": "a/b"}},
+		{"/a.b", "/aXb", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template+" "+tt.path, func(t *testing.T) {
+			tmpl, err := ParsePathTemplate(tt.template)
+			require.NoError(t, err)
+			vars, ok := tmpl.Match(tt.path)
+			assert.Equal(t, tt.matches, ok)
+			if tt.matches {
+				assert.Equal(t, tt.vars, vars)
+			}
+		})
+	}
+}
+
+func TestPathTemplate_LiteralSegments(t *testing.T) {
+	for template, expected := range map[string]int{
+		"/":                   0,
+		"/users/42":           2,
+		"/users/{id}":         1,
+		"/users/{id:[0-9]+}/": 1,
+		"/files/{name}.json":  1,
+		"/api/*/health":       2,
+		"/static/**":          1,
+	} {
+		tmpl, err := ParsePathTemplate(template)
+		require.NoError(t, err)
+		assert.Equal(t, expected, tmpl.LiteralSegments(), template)
+	}
+}
+
+func TestParsePathTemplate_Errors(t *testing.T) {
+	for _, template := range []string{"/a/**/b", "/users/{id", "/users/{}", "/users/{:[0-9]+}", "/users/{id:[0-9}"} {
+		t.Run(template, func(t *testing.T) {
+			_, err := ParsePathTemplate(template)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultProfile names the base scenario set, which is live when no profile is active
+const DefaultProfile = "default"
+
+// ErrUnknownProfile is returned when switching to a profile that no scenario file defines
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// ActiveProfile returns the name of the active scenario profile
+func ActiveProfile() string {
+	configLock.Lock()
+	defer configLock.Unlock()
+	if activeProfile == "" {
+		return DefaultProfile
+	}
+	return activeProfile
+}
+
+// Profiles returns the names of the profiles defined in the loaded scenario files, sorted
+func Profiles() []string {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, s := range loadedScenarios {
+		if s.Profile != "" && !seen[s.Profile] {
+			seen[s.Profile] = true
+			names = append(names, s.Profile)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetProfile switches the active scenario profile. DefaultProfile switches back to the base
+// scenarios. Scenarios that are live in both profiles keep their runtime state, and file
+// scenarios replaced or deleted through the admin API stay that way.
+func SetProfile(name string) error {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	if name == DefaultProfile {
+		name = ""
+	}
+	if name != "" && !hasProfile(loadedScenarios, name) {
+		return fmt.Errorf("%w %q", ErrUnknownProfile, name)
+	}
+
+	activeProfile = name
+	currentConfig.Profile = name
+	replaceFileScenariosLocked(append([]Scenario(nil), loadedScenarios...), scenarioFiles)
+	return nil
+}
+
+func hasProfile(loaded []Scenario, name string) bool {
+	for i := range loaded {
+		if loaded[i].Profile == name {
+			return true
+		}
+	}
+	return false
+}
+
+// activeScenarios returns the scenarios that are live for profile: the profile's scenarios,
+// plus every base scenario whose path and method the profile does not override
+func activeScenarios(loaded []Scenario, profile string) []*Scenario {
+	overridden := make(map[string]bool)
+	if profile != "" {
+		for i := range loaded {
+			if loaded[i].Profile == profile {
+				overridden[scenarioKey(&loaded[i])] = true
+			}
+		}
+	}
+
+	active := make([]*Scenario, 0, len(loaded))
+	for i := range loaded {
+		s := &loaded[i]
+		switch {
+		case s.Profile == "" && !overridden[scenarioKey(s)]:
+			active = append(active, s)
+		case s.Profile != "" && s.Profile == profile:
+			active = append(active, s)
+		}
+	}
+	return active
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const profileScenarios = `
+scenarios:
+  - path: /profile/payments
+    method: POST
+    responses:
+      - status: 200
+  - path: /profile/orders
+    method: GET
+    responses:
+      - status: 200
+profiles:
+  degraded:
+    - path: /profile/payments
+      method: POST
+      responses:
+        - status: 200
+          delay: 2s
+    - path: /profile/refunds
+      method: POST
+      responses:
+        - status: 503
+  outage:
+    - path: /profile/payments
+      method: POST
+      responses:
+        - status: 503
+`
+
+func TestProfiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(profileScenarios), 0o644))
+	_, err := LoadConfig(file)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = SetProfile(DefaultProfile) })
+
+	assert.Equal(t, DefaultProfile, ActiveProfile())
+	assert.Equal(t, []string{"degraded", "outage"}, Profiles())
+	orders := lookupScenario(t, "/profile/orders_GET")
+	orders.Runtime.Index = 1
+	_, ok := GetScenarios().Load("/profile/refunds_POST")
+	assert.False(t, ok, "Profile scenarios should not be live by default")
+
+	require.NoError(t, SetProfile("degraded"))
+	assert.Equal(t, "degraded", ActiveProfile())
+	payments := lookupScenario(t, "/profile/payments_POST")
+	assert.Equal(t, "degraded", payments.Profile)
+	lookupScenario(t, "/profile/refunds_POST")
+	stillLive := lookupScenario(t, "/profile/orders_GET")
+	assert.Equal(t, orders.ID, stillLive.ID, "Base scenarios that are not overridden stay live")
+	assert.Equal(t, int32(1), stillLive.Runtime.Index, "and keep their runtime state")
+
+	require.NoError(t, SetProfile("outage"))
+	assert.Equal(t, 503, lookupScenario(t, "/profile/payments_POST").Responses[0].Status)
+	_, ok = GetScenarios().Load("/profile/refunds_POST")
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, SetProfile("missing"), ErrUnknownProfile)
+	assert.Equal(t, "outage", ActiveProfile())
+
+	// The active profile survives a reload while it is still defined
+	require.NoError(t, ReloadScenarios())
+	assert.Equal(t, "outage", ActiveProfile())
+
+	require.NoError(t, SetProfile(DefaultProfile))
+	assert.Empty(t, lookupScenario(t, "/profile/payments_POST").Profile)
+}
+
+func TestSetProfile_KeepsAdminChanges(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(profileScenarios), 0o644))
+	_, err := LoadConfig(file)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = SetProfile(DefaultProfile) })
+
+	orders := lookupScenario(t, "/profile/orders_GET")
+	patched := orders.Definition()
+	patched.Responses = []Response{{Status: 202}}
+	PutScenario(orders.ID, &patched)
+	require.True(t, DeleteScenario(lookupScenario(t, "/profile/payments_POST").ID))
+
+	require.NoError(t, SetProfile("outage"))
+	require.NoError(t, SetProfile(DefaultProfile))
+	assert.Equal(t, 202, lookupScenario(t, "/profile/orders_GET").Responses[0].Status, "Patched file scenario keeps its patch")
+	_, ok := GetScenarios().Load("/profile/payments_POST")
+	assert.False(t, ok, "Deleted file scenario does not come back")
+
+	// A reload re-reads the files
+	require.NoError(t, ReloadScenarios())
+	assert.Equal(t, 200, lookupScenario(t, "/profile/orders_GET").Responses[0].Status)
+	lookupScenario(t, "/profile/payments_POST")
+}
+
+func TestLoad_Profile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(profileScenarios), 0o644))
+	t.Cleanup(func() { _ = SetProfile(DefaultProfile) })
+
+	t.Setenv("SCENARIO_PROFILE", "degraded")
+	_, err := LoadConfig(file)
+	require.NoError(t, err)
+	assert.Equal(t, "degraded", ActiveProfile())
+
+	t.Setenv("SCENARIO_PROFILE", "missing")
+	_, err = LoadConfig(file)
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+}
+
+func TestLoadScenarios_InvalidProfileName(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	writeFile(t, file, "profiles:\n  default:\n    - path: /a\n      method: GET\n      responses:\n        - status: 200\n")
+
+	_, _, err := loadScenarios(false, file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), file+":2:3: profile name \"default\" is reserved")
+}
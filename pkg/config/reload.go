@@ -0,0 +1,190 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Definition returns a copy of the scenario's definition, without its runtime state.
+// Runtime state is behind a pointer, so the copy never reads counters other requests update.
+func (s *Scenario) Definition() Scenario {
+	def := *s
+	def.Runtime = nil
+	return def
+}
+
+// fingerprint returns a stable representation of a scenario's definition,
+// ignoring its ID, origin and runtime state
+func fingerprint(s *Scenario) string {
+	def := s.Definition()
+	def.ID = ""
+	def.Source = ""
+	return fmt.Sprintf("%#v", def)
+}
+
+// replaceFileScenariosLocked atomically swaps the previously loaded file scenarios for loaded.
+// Only the base scenarios and those of the active profile go live (see activeScenarios).
+// Scenarios added at runtime are kept unless a file scenario now uses their ID, and scenarios
+// whose definition did not change keep their ID, sequence index and circuit breaker state.
+// Caller must hold configLock.
+func replaceFileScenariosLocked(loaded []Scenario, files []string) {
+	previous := make(map[string][]*Scenario, len(fileScenarios))
+	fromFile := make(map[*Scenario]bool, len(fileScenarios))
+	for _, s := range fileScenarios {
+		fp := fingerprint(s)
+		previous[fp] = append(previous[fp], s)
+		fromFile[s] = true
+	}
+
+	fileIDs := make(map[string]bool, len(loaded))
+	for i := range loaded {
+		s := &loaded[i]
+		fp := fingerprint(s)
+		if olds := previous[fp]; len(olds) > 0 {
+			old := olds[0]
+			previous[fp] = olds[1:]
+			if s.ID == "" {
+				s.ID = old.ID
+			}
+			s.Runtime = old.Runtime
+		}
+		if s.ID != "" {
+			fileIDs[s.ID] = true
+		}
+	}
+
+	// IDs are assigned to inactive profile scenarios too, so they are stable across switches
+	current := scenarios.Load()
+	definitions := make([]Scenario, len(loaded))
+	for i := range loaded {
+		s := &loaded[i]
+		if s.ID == "" {
+			s.ID = nextFreeScenarioID(func(id string) bool {
+				if fileIDs[id] {
+					return true
+				}
+				existing := findScenarioLocked(current, id)
+				return existing != nil && !fromFile[existing]
+			})
+			fileIDs[s.ID] = true
+		}
+		definitions[i] = s.Definition()
+	}
+
+	if activeProfile != "" && !hasProfile(loaded, activeProfile) {
+		log.Printf("Warning: Profile %q is no longer defined, switching to the %s profile", activeProfile, DefaultProfile)
+		activeProfile = ""
+		currentConfig.Profile = ""
+	}
+
+	next := &sync.Map{}
+	newFileScenarios := activeScenarios(loaded, activeProfile)
+	order := append([]*Scenario(nil), newFileScenarios...)
+	for _, s := range newFileScenarios {
+		initRuntimeState(s)
+		storeScenario(next, s)
+	}
+
+	// Keep scenarios that were added at runtime (e.g. via POST /scenario), after the files
+	for _, s := range LiveScenarioOrder().Scenarios {
+		if !fromFile[s] && !fileIDs[s.ID] {
+			storeScenario(next, s)
+			order = append(order, s)
+		}
+	}
+
+	scenarios.Store(next)
+	setScenarioOrderLocked(order)
+	fileScenarios = newFileScenarios
+	loadedScenarios = definitions
+	scenarioFiles = files
+	currentConfig.Scenarios = loaded
+}
+
+// ReloadScenarios re-reads the configured scenario sources and atomically replaces the
+// scenarios and resources loaded from them. Server settings are not reloaded.
+// If any file cannot be read, parsed or validated, the current scenario set stays live.
+func ReloadScenarios() error {
+	configLock.Lock()
+	paths := append([]string(nil), scenarioPaths...)
+	strict := currentConfig.StrictScenarios
+	configLock.Unlock()
+
+	l, err := loadScenarioSources(strict, paths...)
+	if err != nil {
+		return err
+	}
+
+	configLock.Lock()
+	defer configLock.Unlock()
+	replaceResources(l.resources)
+	replaceFileScenariosLocked(l.scenarios, l.files)
+	return nil
+}
+
+// scenarioSignature summarizes the modification state of the scenario sources for change detection.
+// It covers every file read by the last successful load (including includes) and any
+// scenario file that has been added to a scenario directory since.
+func scenarioSignature() string {
+	configLock.Lock()
+	paths := append([]string(nil), scenarioPaths...)
+	files := append([]string(nil), scenarioFiles...)
+	configLock.Unlock()
+
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			if listed, err := scenarioDirFiles(path); err == nil {
+				files = append(files, listed...)
+			}
+		} else {
+			files = append(files, path)
+		}
+	}
+
+	var b strings.Builder
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			fmt.Fprintf(&b, "%s|%d|%d\n", f, info.ModTime().UnixNano(), info.Size())
+		} else {
+			fmt.Fprintf(&b, "%s|missing\n", f)
+		}
+	}
+	return b.String()
+}
+
+// WatchScenarios polls the scenario sources every interval and reloads them when any
+// scenario file changes. onReload, if set, receives the result of every reload.
+// It blocks until ctx is cancelled.
+func WatchScenarios(ctx context.Context, interval time.Duration, onReload func(error)) {
+	last := scenarioSignature()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := scenarioSignature()
+			if current == last {
+				continue
+			}
+			last = current
+
+			err := ReloadScenarios()
+			if onReload != nil {
+				onReload(err)
+			}
+			if err == nil {
+				// The set of files may have changed (e.g. a new include)
+				last = scenarioSignature()
+			}
+		}
+	}
+}
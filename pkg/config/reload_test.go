@@ -0,0 +1,174 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reloadScenariosV1 = `
+- path: /reload/stable
+  method: GET
+  responses:
+    - status: 200
+    - status: 503
+- path: /reload/changing
+  method: GET
+  responses:
+    - status: 200
+`
+
+const reloadScenariosV2 = `
+- path: /reload/stable
+  method: GET
+  responses:
+    - status: 200
+    - status: 503
+- path: /reload/changing
+  method: GET
+  responses:
+    - status: 418
+- path: /reload/added
+  method: POST
+  responses:
+    - status: 201
+`
+
+func lookupScenario(t *testing.T, key string) *Scenario {
+	t.Helper()
+	v, ok := GetScenarios().Load(key)
+	require.True(t, ok, "Expected scenario %s to be loaded", key)
+	list := v.([]*Scenario)
+	require.Len(t, list, 1, "Expected exactly one scenario for %s", key)
+	return list[0]
+}
+
+func TestReloadScenarios(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(reloadScenariosV1), 0o644))
+
+	_, err := LoadConfig(file)
+	require.NoError(t, err)
+
+	// Runtime-added scenarios must survive a reload
+	AddScenario(&Scenario{Path: "/reload/runtime", Method: "GET", Responses: []Response{{Status: 200}}})
+
+	stable := lookupScenario(t, "/reload/stable_GET")
+	stable.Runtime.Index = 1
+	stable.Runtime.CBState.State = "open"
+	lookupScenario(t, "/reload/changing_GET").Runtime.Index = 0
+
+	require.NoError(t, os.WriteFile(file, []byte(reloadScenariosV2), 0o644))
+	require.NoError(t, ReloadScenarios())
+
+	reloadedStable := lookupScenario(t, "/reload/stable_GET")
+	assert.Equal(t, int32(1), reloadedStable.Runtime.Index, "Unchanged scenario should keep its index")
+	assert.Same(t, stable.Runtime.CBState, reloadedStable.Runtime.CBState, "Unchanged scenario should keep its circuit breaker state")
+
+	changing := lookupScenario(t, "/reload/changing_GET")
+	assert.Equal(t, 418, changing.Responses[0].Status, "Changed scenario should be replaced")
+	assert.Equal(t, "closed", changing.Runtime.CBState.State)
+
+	lookupScenario(t, "/reload/added_POST")
+	lookupScenario(t, "/reload/runtime_GET")
+
+	// An invalid file keeps the current set live
+	require.NoError(t, os.WriteFile(file, []byte("- path: [unterminated"), 0o644))
+	assert.Error(t, ReloadScenarios())
+	lookupScenario(t, "/reload/added_POST")
+
+	// A scenario without responses is rejected
+	require.NoError(t, os.WriteFile(file, []byte("- path: /reload/empty\n  method: GET\n"), 0o644))
+	assert.Error(t, ReloadScenarios())
+	_, ok := GetScenarios().Load("/reload/empty_GET")
+	assert.False(t, ok, "Invalid scenario should not be loaded")
+}
+
+// simulateTraffic updates the runtime state of every live scenario the way requests do,
+// until the returned function is called
+func simulateTraffic() (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			GetScenarios().Range(func(_, value interface{}) bool {
+				for _, s := range value.([]*Scenario) {
+					idx := atomic.LoadInt32(&s.Runtime.Index)
+					atomic.CompareAndSwapInt32(&s.Runtime.Index, idx, idx+1)
+				}
+				return true
+			})
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func TestReloadScenarios_UnderTraffic(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(reloadScenariosV1), 0o644))
+	_, err := LoadConfig(file)
+	require.NoError(t, err)
+
+	// Run with -race: reloads compare live scenarios while their counters change
+	stop := simulateTraffic()
+	stable := lookupScenario(t, "/reload/stable_GET")
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&stable.Runtime.Index) > 0 }, time.Second, time.Millisecond)
+	for i := 0; i < 20; i++ {
+		data := reloadScenariosV1
+		if i%2 == 1 {
+			data = reloadScenariosV2
+		}
+		require.NoError(t, os.WriteFile(file, []byte(data), 0o644))
+		require.NoError(t, ReloadScenarios())
+	}
+	stop()
+
+	assert.Same(t, stable.Runtime, lookupScenario(t, "/reload/stable_GET").Runtime, "Unchanged scenario keeps its runtime state across reloads")
+}
+
+func TestWatchScenarios(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(reloadScenariosV1), 0o644))
+	_, err := LoadConfig(file)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloaded := make(chan error, 1)
+	go WatchScenarios(ctx, 10*time.Millisecond, func(err error) {
+		select {
+		case reloaded <- err:
+		default:
+		}
+	})
+	// Give the watcher time to record the initial file state
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(file, []byte(reloadScenariosV2), 0o644))
+
+	select {
+	case err := <-reloaded:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Scenario file change was not picked up")
+	}
+	lookupScenario(t, "/reload/added_POST")
+}
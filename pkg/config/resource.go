@@ -0,0 +1,292 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultIDField is the item field that holds the ID of a resource item
+const DefaultIDField = "id"
+
+// ErrDuplicateID is returned when creating an item whose ID is already taken
+var ErrDuplicateID = errors.New("an item with this ID already exists")
+
+// Resource declares an in-memory REST collection. Its operations are served by scenarios
+// generated for the collection path and the item path (path + "/{id}"), so circuit
+// breakers, priorities and profiles apply to them as to any scenario.
+type Resource struct {
+	Path           string               `yaml:"path" json:"path"`                                 // Collection path, e.g. /api/users
+	IDField        string               `yaml:"idField,omitempty" json:"idField,omitempty"`       // Item field holding its ID, default "id"
+	Seed           []JSONValue          `yaml:"seed,omitempty" json:"seed,omitempty"`             // Items the collection starts with
+	Delay          time.Duration        `yaml:"delay,omitempty" json:"delay,omitempty"`           // Added to every operation
+	DelayRange     string               `yaml:"delayRange,omitempty" json:"delayRange,omitempty"` // e.g., "100ms-500ms"
+	Faults         []Response           `yaml:"faults,omitempty" json:"faults,omitempty"`         // Served instead of the operation, each with its probability
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuitBreaker,omitempty" json:"circuitBreaker"`
+}
+
+// ItemPath returns the path template of a single item
+func (r *Resource) ItemPath() string {
+	return r.Path + "/{id}"
+}
+
+// ItemIDField returns the item field holding the ID
+func (r *Resource) ItemIDField() string {
+	if r.IDField != "" {
+		return r.IDField
+	}
+	return DefaultIDField
+}
+
+// scenarios returns the scenarios that serve the resource's operations
+func (r *Resource) scenarios() []Scenario {
+	routes := []struct{ method, path string }{
+		{http.MethodGet, r.Path},
+		{http.MethodPost, r.Path},
+		{http.MethodGet, r.ItemPath()},
+		{http.MethodPut, r.ItemPath()},
+		{http.MethodPatch, r.ItemPath()},
+		{http.MethodDelete, r.ItemPath()},
+	}
+	scenarios := make([]Scenario, len(routes))
+	for i, route := range routes {
+		scenarios[i] = Scenario{Path: route.path, Method: route.method, Resource: r.Path, CircuitBreaker: r.CircuitBreaker}
+	}
+	return scenarios
+}
+
+// ItemID returns the ID of an item as used in item paths: strings as is, integral numbers
+// in decimal. Other values are not valid IDs.
+func ItemID(v interface{}) (string, bool) {
+	switch id := v.(type) {
+	case string:
+		return id, id != ""
+	case float64:
+		if id != float64(int64(id)) {
+			return "", false
+		}
+		return strconv.FormatInt(int64(id), 10), true
+	}
+	return "", false
+}
+
+// Collection is the in-memory store of a resource. Items are kept in insertion order and
+// never modified in place, so the items it returns may be read without holding its lock.
+type Collection struct {
+	Resource Resource // Definition the collection was declared with
+	items    *collectionItems
+}
+
+// collectionItems is shared by the collections of a resource across reloads
+type collectionItems struct {
+	mutex      sync.Mutex
+	ids        []string
+	items      map[string]map[string]interface{}
+	nextID     int64
+	numericIDs bool // Generate numbers rather than strings, following the seed
+}
+
+var (
+	collectionsMutex sync.RWMutex
+	collections      = map[string]*Collection{} // Resource path to collection
+	loadedResources  []Resource                 // Definitions of the loaded resources, in file order
+)
+
+// LookupCollection returns the collection of the resource at path, or nil
+func LookupCollection(path string) *Collection {
+	collectionsMutex.RLock()
+	defer collectionsMutex.RUnlock()
+	return collections[path]
+}
+
+// Resources returns the definitions of the loaded resources
+func Resources() []Resource {
+	collectionsMutex.RLock()
+	defer collectionsMutex.RUnlock()
+	return append([]Resource(nil), loadedResources...)
+}
+
+// replaceResources swaps the live collections for those of resources. Collections whose
+// ID field and seed did not change keep their items.
+func replaceResources(resources []Resource) {
+	collectionsMutex.Lock()
+	defer collectionsMutex.Unlock()
+
+	next := make(map[string]*Collection, len(resources))
+	for _, r := range resources {
+		c := &Collection{Resource: r}
+		if old := collections[r.Path]; old != nil && seedFingerprint(&old.Resource) == seedFingerprint(&r) {
+			c.items = old.items
+		} else {
+			c.items = &collectionItems{}
+			c.reset()
+		}
+		next[r.Path] = c
+	}
+	collections = next
+	loadedResources = resources
+}
+
+func seedFingerprint(r *Resource) string {
+	return fmt.Sprintf("%s|%q", r.ItemIDField(), r.Seed)
+}
+
+// ResetCollections restores the seed items of the named resources, or of every resource if
+// none are named
+func ResetCollections(paths ...string) {
+	collectionsMutex.RLock()
+	defer collectionsMutex.RUnlock()
+	if len(paths) == 0 {
+		for _, c := range collections {
+			c.reset()
+		}
+		return
+	}
+	for _, path := range paths {
+		if c := collections[path]; c != nil {
+			c.reset()
+		}
+	}
+}
+
+// reset replaces the items with the seed. Seed items were validated when loaded.
+func (c *Collection) reset() {
+	s := c.items
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var seed []map[string]interface{}
+	for _, raw := range c.Resource.Seed {
+		var item map[string]interface{}
+		if err := json.Unmarshal(raw, &item); err == nil && item != nil {
+			seed = append(seed, item)
+		}
+	}
+
+	// Generated IDs continue after the highest numeric seed ID, in the seed's ID type
+	s.ids, s.items, s.nextID, s.numericIDs = nil, make(map[string]map[string]interface{}), 1, true
+	idField := c.Resource.ItemIDField()
+	for _, item := range seed {
+		if _, isString := item[idField].(string); isString {
+			s.numericIDs = false
+		}
+		if id, ok := ItemID(item[idField]); ok {
+			if n, err := strconv.ParseInt(id, 10, 64); err == nil && n >= s.nextID {
+				s.nextID = n + 1
+			}
+		}
+	}
+	for _, item := range seed {
+		_, _ = c.createLocked(item)
+	}
+}
+
+// List returns every item in insertion order
+func (c *Collection) List() []map[string]interface{} {
+	s := c.items
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list := make([]map[string]interface{}, len(s.ids))
+	for i, id := range s.ids {
+		list[i] = s.items[id]
+	}
+	return list
+}
+
+// Get returns the item with the given ID
+func (c *Collection) Get(id string) (map[string]interface{}, bool) {
+	s := c.items
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	item, ok := s.items[id]
+	return item, ok
+}
+
+// Create adds an item and returns its ID. Items without an ID are given the next free one.
+func (c *Collection) Create(item map[string]interface{}) (string, error) {
+	c.items.mutex.Lock()
+	defer c.items.mutex.Unlock()
+	return c.createLocked(item)
+}
+
+func (c *Collection) createLocked(item map[string]interface{}) (string, error) {
+	s := c.items
+	idField := c.Resource.ItemIDField()
+	if _, ok := item[idField]; !ok {
+		n := s.nextID
+		for s.items[strconv.FormatInt(n, 10)] != nil {
+			n++
+		}
+		s.nextID = n + 1
+		if s.numericIDs {
+			item[idField] = float64(n)
+		} else {
+			item[idField] = strconv.FormatInt(n, 10)
+		}
+	}
+
+	id, ok := ItemID(item[idField])
+	if !ok {
+		return "", fmt.Errorf("%s must be a string or an integer", idField)
+	}
+	if _, taken := s.items[id]; taken {
+		return "", fmt.Errorf("%w: %s", ErrDuplicateID, id)
+	}
+	s.ids = append(s.ids, id)
+	s.items[id] = item
+	return id, nil
+}
+
+// Replace stores item under an existing ID. It reports false if there is no such item.
+func (c *Collection) Replace(id string, item map[string]interface{}) bool {
+	s := c.items
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.items[id]; !ok {
+		return false
+	}
+	s.items[id] = item
+	return true
+}
+
+// Update merges the top-level fields of patch into an existing item and returns the result
+func (c *Collection) Update(id string, patch map[string]interface{}) (map[string]interface{}, bool) {
+	s := c.items
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	old, ok := s.items[id]
+	if !ok {
+		return nil, false
+	}
+	item := make(map[string]interface{}, len(old)+len(patch))
+	for k, v := range old {
+		item[k] = v
+	}
+	for k, v := range patch {
+		item[k] = v
+	}
+	s.items[id] = item
+	return item, true
+}
+
+// Delete removes an item. It reports false if there is no such item.
+func (c *Collection) Delete(id string) bool {
+	s := c.items
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.items[id]; !ok {
+		return false
+	}
+	delete(s.items, id)
+	for i, existing := range s.ids {
+		if existing == id {
+			s.ids = append(s.ids[:i:i], s.ids[i+1:]...)
+			break
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const resourceScenarios = `
+scenarios:
+  - path: /res/health
+    method: GET
+    responses:
+      - status: 200
+resources:
+  - path: /res/users
+    seed:
+      - id: 1
+        name: Alice
+      - id: 2
+        name: Bob
+    delay: 10ms
+    faults:
+      - status: 503
+        probability: 0.1
+    circuitBreaker:
+      failureThreshold: 3
+      timeout: 5s
+`
+
+func TestLoadScenarios_Resources(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	writeFile(t, file, resourceScenarios)
+
+	l, err := loadScenarioSources(true, file)
+	require.NoError(t, err)
+	require.Len(t, l.resources, 1)
+	assert.Equal(t, "/res/users", l.resources[0].Path)
+	assert.Len(t, l.resources[0].Seed, 2)
+
+	var routes []string
+	for _, s := range l.scenarios[1:] {
+		routes = append(routes, s.Method+" "+s.Path)
+		assert.Equal(t, "/res/users", s.Resource)
+		assert.Equal(t, file, s.Source)
+		assert.Equal(t, 3, s.CircuitBreaker.FailureThreshold, "Resource scenarios share the circuit breaker settings")
+	}
+	assert.Equal(t, []string{"GET /res/users", "POST /res/users", "GET /res/users/{id}", "PUT /res/users/{id}",
+		"PATCH /res/users/{id}", "DELETE /res/users/{id}"}, routes)
+}
+
+func TestCollection(t *testing.T) {
+	replaceResources([]Resource{{Path: "/res/orders", Seed: []JSONValue{JSONValue(`{"id": 7, "total": 10}`)}}})
+	c := LookupCollection("/res/orders")
+	require.NotNil(t, c)
+
+	item, ok := c.Get("7")
+	require.True(t, ok)
+	assert.Equal(t, 10.0, item["total"])
+
+	created := map[string]interface{}{"total": 20.0}
+	id, err := c.Create(created)
+	require.NoError(t, err)
+	assert.Equal(t, "8", id, "Generated IDs continue after the highest seed ID")
+	assert.Equal(t, 8.0, created["id"], "Numeric seed IDs yield numeric IDs")
+
+	_, err = c.Create(map[string]interface{}{"id": "7"})
+	assert.ErrorIs(t, err, ErrDuplicateID)
+	_, err = c.Create(map[string]interface{}{"id": 1.5})
+	assert.Error(t, err)
+
+	updated, ok := c.Update("8", map[string]interface{}{"paid": true})
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"id": 8.0, "total": 20.0, "paid": true}, updated)
+	assert.Equal(t, map[string]interface{}{"total": 20.0, "id": 8.0}, created, "Items are not modified in place")
+
+	assert.True(t, c.Replace("7", map[string]interface{}{"id": 7.0}))
+	assert.False(t, c.Replace("9", map[string]interface{}{"id": 9.0}))
+	assert.True(t, c.Delete("7"))
+	assert.False(t, c.Delete("7"))
+	assert.Len(t, c.List(), 1)
+
+	ResetCollections("/res/orders")
+	list := c.List()
+	require.Len(t, list, 1)
+	assert.Equal(t, 10.0, list[0]["total"])
+}
+
+func TestCollection_StringIDs(t *testing.T) {
+	replaceResources([]Resource{{Path: "/res/tags", IDField: "slug", Seed: []JSONValue{JSONValue(`{"slug": "go"}`)}}})
+	c := LookupCollection("/res/tags")
+
+	item := map[string]interface{}{}
+	id, err := c.Create(item)
+	require.NoError(t, err)
+	assert.Equal(t, "1", id)
+	assert.Equal(t, "1", item["slug"], "String seed IDs yield string IDs")
+}
+
+func TestReplaceResources(t *testing.T) {
+	seed := []JSONValue{JSONValue(`{"id": 1}`)}
+	replaceResources([]Resource{{Path: "/res/carts", Seed: seed}})
+	_, err := LookupCollection("/res/carts").Create(map[string]interface{}{})
+	require.NoError(t, err)
+
+	replaceResources([]Resource{{Path: "/res/carts", Seed: seed, Delay: 5}})
+	assert.Len(t, LookupCollection("/res/carts").List(), 2, "Items survive reloads that keep the seed")
+	assert.EqualValues(t, 5, LookupCollection("/res/carts").Resource.Delay)
+
+	replaceResources([]Resource{{Path: "/res/carts", Seed: append(seed, JSONValue(`{"id": 2}`))}})
+	assert.Len(t, LookupCollection("/res/carts").List(), 2, "A new seed replaces the items")
+
+	replaceResources(nil)
+	assert.Nil(t, LookupCollection("/res/carts"))
+	assert.Empty(t, Resources())
+}
+
+func TestExportScenarios_Resources(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	writeFile(t, file, resourceScenarios)
+	_, err := LoadConfig(file)
+	require.NoError(t, err)
+
+	data, err := ExportScenarios("json", false)
+	require.NoError(t, err)
+	var doc struct {
+		Scenarios []map[string]interface{} `json:"scenarios"`
+		Resources []map[string]interface{} `json:"resources"`
+	}
+	require.NoError(t, json.Unmarshal(data, &doc), string(data))
+	for _, s := range doc.Scenarios {
+		assert.NotEqual(t, "/res/users", s["path"], "Generated scenarios are exported as their resource")
+	}
+	require.Len(t, doc.Resources, 1)
+	assert.Equal(t, "/res/users", doc.Resources[0]["path"])
+	assert.Equal(t, "10ms", doc.Resources[0]["delay"])
+
+	exported := filepath.Join(t.TempDir(), "export.json")
+	require.NoError(t, os.WriteFile(exported, data, 0o644))
+	loaded, _, err := loadScenarios(false, exported)
+	require.NoError(t, err)
+	assert.Len(t, loaded, len(ListScenarios()))
+}
@@ -0,0 +1,335 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	durationType      = reflect.TypeOf(time.Duration(0))
+	jsonBodyType      = reflect.TypeOf(JSONBody(nil))
+	jsonValueType     = reflect.TypeOf(JSONValue(nil))
+	numberType        = reflect.TypeOf(Number(""))
+	stringMatcherType = reflect.TypeOf(StringMatcher{})
+	bodyMatcherType   = reflect.TypeOf(BodyMatcher{})
+	matchListType     = reflect.TypeOf(MatchList(nil))
+	scenarioStateType = reflect.TypeOf(ScenarioState{})
+)
+
+// schemaField is a field as it appears in scenario YAML
+type schemaField struct {
+	name string
+	typ  reflect.Type
+}
+
+// yamlFields lists the fields of struct type t under their YAML names, following
+// the same rules as yaml.v3: "-" is skipped, inline structs are flattened and
+// untagged fields use their lower-cased Go name.
+func yamlFields(t reflect.Type) []schemaField {
+	var fields []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "inline") {
+			fields = append(fields, yamlFields(f.Type)...)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields = append(fields, schemaField{name: name, typ: f.Type})
+	}
+	return fields
+}
+
+// documentKeys are the top-level keys of the mapping form of a scenario file (see scenarioDocument).
+// The config file is also read as a scenario source, so server settings are allowed as well.
+func documentKeys() []schemaField {
+	return append(yamlFields(reflect.TypeOf(scenarioDocument{})), yamlFields(reflect.TypeOf(Config{}))...)
+}
+
+// unknownFields reports every mapping key in node that does not correspond to a field of t,
+// recursing into nested structs and lists. name is the dotted path used in messages.
+func unknownFields(file string, node *yaml.Node, t reflect.Type, name string) []Problem {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node == nil || t == jsonBodyType || t == jsonValueType || t == durationType || t == scenarioStateType {
+		return nil
+	}
+	if t == bodyMatcherType && !isBodyOperatorMapping(node) {
+		return nil // String shorthand or legacy structured body
+	}
+	if t == matchListType && node.Kind == yaml.MappingNode {
+		return unknownFields(file, node, t.Elem(), name) // Single block
+	}
+
+	var problems []Problem
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldName := joinFieldName(name, key.Value)
+			if f, ok := lookupField(fields, key.Value); ok {
+				problems = append(problems, unknownFields(file, value, f.typ, fieldName)...)
+				continue
+			}
+			problems = append(problems, Problem{File: file, Line: key.Line, Column: key.Column,
+				Message: unknownFieldMessage(fieldName, key.Value, fields)})
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			problems = append(problems, unknownFields(file, item, t.Elem(), fmt.Sprintf("%s[%d]", name, i))...)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			problems = append(problems, unknownFields(file, node.Content[i+1], t.Elem(), joinFieldName(name, node.Content[i].Value))...)
+		}
+	}
+	return problems
+}
+
+// unknownDocumentKeys reports top-level keys of a mapping scenario file that are not recognized
+func unknownDocumentKeys(file string, node *yaml.Node) []Problem {
+	fields := documentKeys()
+	var problems []Problem
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if _, ok := lookupField(fields, key.Value); !ok {
+			problems = append(problems, Problem{File: file, Line: key.Line, Column: key.Column,
+				Message: unknownFieldMessage(key.Value, key.Value, fields)})
+		}
+	}
+	return problems
+}
+
+func lookupField(fields []schemaField, name string) (schemaField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return schemaField{}, false
+}
+
+func joinFieldName(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// unknownFieldMessage suggests the intended field for typos that only differ in case, e.g. delayrange
+func unknownFieldMessage(fieldName, key string, fields []schemaField) string {
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return fmt.Sprintf("unknown field %q (did you mean %q?)", fieldName, f.name)
+		}
+	}
+	return fmt.Sprintf("unknown field %q", fieldName)
+}
+
+// schemaOverrides adds constraints to generated properties that the Go types cannot express,
+// keyed by "Type.field"
+var schemaOverrides = map[string]map[string]interface{}{
+	"Scenario.id":                           {"pattern": validID.String()},
+	"Scenario.path":                         {"pattern": "^/"},
+	"Scenario.method":                       {"enum": sortedMethods()},
+	"Scenario.strategy":                     {"enum": Strategies},
+	"Scenario.onExhausted":                  {"type": []string{"string", "integer"}, "enum": []interface{}{ExhaustedLoop, ExhaustedStickLast, ExhaustedFallthrough, ExhaustedNotFound, 404}}, // 404 unquoted in YAML
+	"JSONPathMatcher.path":                  {"description": "JSONPath expression, e.g. $.items[*].sku"},
+	"GraphQLMatcher.operationType":          {"enum": GraphQLOperationTypes},
+	"GraphQLError.path":                     {"type": "array", "items": map[string]interface{}{"type": []string{"string", "integer"}}},
+	"GraphQLError.extensions":               {"type": "object"},
+	"PartMatcher.minSize":                   {"minimum": 0},
+	"PartMatcher.maxSize":                   {"minimum": 0},
+	"Response.status":                       {"minimum": 100, "maximum": 599},
+	"Response.delayRange":                   {"pattern": `^\s*\S+\s*-\s*\S+\s*$`, "description": "Random delay range, e.g. 100ms-500ms"},
+	"Response.probability":                  {"minimum": 0, "maximum": 1},
+	"Response.weight":                       {"minimum": 0},
+	"Response.times":                        {"minimum": 0, "maximum": MaxSequenceSteps},
+	"Resource.path":                         {"pattern": "^/.*[^/]$", "description": "Collection path without path variables, e.g. /api/users"},
+	"Resource.seed":                         {"type": "array", "items": map[string]interface{}{"type": "object"}},
+	"Resource.delayRange":                   {"pattern": `^\s*\S+\s*-\s*\S+\s*$`, "description": "Random delay range, e.g. 100ms-500ms"},
+	"CircuitBreakerConfig.failureThreshold": {"minimum": 0},
+	"CircuitBreakerConfig.successThreshold": {"minimum": 0},
+}
+
+// schemaRequired lists required properties per type
+var schemaRequired = map[string][]string{
+	"Scenario":        {"path", "method", "responses"},
+	"Response":        {"status"},
+	"JSONPathMatcher": {"path"},
+	"PartMatcher":     {"name"},
+	"GraphQLError":    {"message"},
+	"Resource":        {"path"},
+}
+
+func sortedMethods() []string {
+	methods := make([]string, 0, len(validMethods))
+	for m := range validMethods {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// ScenarioSchema returns a JSON Schema (draft 2020-12) for scenario files, generated from the
+// Go types. A file is either a list of scenarios or a mapping with include, scenarios,
+// profiles, resources and, for the config file, server settings.
+func ScenarioSchema() map[string]interface{} {
+	g := &schemaGenerator{defs: make(map[string]interface{})}
+	scenarioList := map[string]interface{}{"type": "array", "items": g.schema(reflect.TypeOf(Scenario{}))}
+	// Exports taken with ?state=true carry each scenario's runtime state, which loading ignores
+	scenario := g.defs["Scenario"].(map[string]interface{})
+	scenario["properties"].(map[string]interface{})["state"] = g.schema(scenarioStateType)
+
+	document := g.object(reflect.TypeOf(Config{}))
+	properties := document["properties"].(map[string]interface{})
+	properties["include"] = map[string]interface{}{
+		"description": "Scenario files to load first, relative to this file; globs are allowed",
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+	properties["scenarios"] = scenarioList
+	properties["profiles"] = map[string]interface{}{
+		"description":          "Named profiles whose scenarios override the base scenarios with the same path and method",
+		"type":                 "object",
+		"propertyNames":        map[string]interface{}{"pattern": validID.String(), "not": map[string]interface{}{"const": DefaultProfile}},
+		"additionalProperties": scenarioList,
+	}
+	properties["resources"] = map[string]interface{}{
+		"description": "In-memory REST collections served with list, get, create, update and delete",
+		"type":        "array",
+		"items":       g.schema(reflect.TypeOf(Resource{})),
+	}
+
+	return map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "go-resilience-mock scenarios",
+		"oneOf":   []interface{}{scenarioList, document},
+		"$defs":   g.defs,
+	}
+}
+
+// schemaGenerator builds schemas for Go types, collecting struct schemas in $defs
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType:
+		return map[string]interface{}{"type": "string", "pattern": `^(\d+(\.\d+)?(ns|us|µs|ms|s|m|h))+$`, "description": "Duration, e.g. 250ms or 1m30s"}
+	case t == jsonBodyType:
+		return map[string]interface{}{"description": "A string, or structured YAML/JSON that is sent as JSON"}
+	case t == jsonValueType:
+		return map[string]interface{}{"description": "Any JSON value"}
+	case t == numberType:
+		return map[string]interface{}{"type": "number"}
+	case t == scenarioStateType:
+		return map[string]interface{}{"type": "object", "description": "Runtime state written by exports with ?state=true; ignored when loading"}
+	case t == stringMatcherType:
+		return g.shorthand(t, "An exact value, or an object of operators that must all hold")
+	case t == matchListType:
+		block := g.schema(t.Elem())
+		return map[string]interface{}{
+			"description": "A match block, or a list of blocks",
+			"oneOf":       []interface{}{block, map[string]interface{}{"type": "array", "items": block}},
+		}
+	case t == bodyMatcherType:
+		body := g.shorthand(t, "A substring, /regex/ or regex:pattern, an object of operators that must all hold, "+
+			"or other structured YAML, matched as contained compact JSON (legacy; prefer jsonBody)")
+		body["oneOf"] = append(body["oneOf"].([]interface{}), legacyBodySchema())
+		return body
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // Placeholder for recursive types
+			g.defs[t.Name()] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// legacyBodySchema matches the structured values matches.body took before it had operators:
+// a list, or a mapping without operator keys
+func legacyBodySchema() map[string]interface{} {
+	var operators []interface{}
+	for _, f := range yamlFields(bodyMatcherType) {
+		operators = append(operators, map[string]interface{}{"required": []string{f.name}})
+	}
+	return map[string]interface{}{
+		"type":          []string{"object", "array"},
+		"minProperties": 1, // An empty mapping is the operator form
+		"not":           map[string]interface{}{"type": "object", "anyOf": operators},
+	}
+}
+
+// shorthand returns a schema for a struct type that may also be written as a plain string
+func (g *schemaGenerator) shorthand(t reflect.Type, description string) map[string]interface{} {
+	if _, ok := g.defs[t.Name()]; !ok {
+		g.defs[t.Name()] = g.object(t)
+	}
+	return map[string]interface{}{
+		"description": description,
+		"oneOf":       []interface{}{map[string]interface{}{"type": "string"}, map[string]interface{}{"$ref": "#/$defs/" + t.Name()}},
+	}
+}
+
+// object returns an inline object schema for struct type t that rejects unknown properties
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for _, f := range yamlFields(t) {
+		prop := g.schema(f.typ)
+		for k, v := range schemaOverrides[t.Name()+"."+f.name] {
+			prop[k] = v
+		}
+		properties[f.name] = prop
+	}
+
+	obj := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if required, ok := schemaRequired[t.Name()]; ok {
+		obj["required"] = required
+	}
+	return obj
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenarioSchema(t *testing.T) {
+	schema := ScenarioSchema()
+	_, err := json.Marshal(schema)
+	require.NoError(t, err)
+
+	defs := schema["$defs"].(map[string]interface{})
+	scenario := defs["Scenario"].(map[string]interface{})
+	assert.Equal(t, false, scenario["additionalProperties"])
+	assert.Equal(t, []string{"path", "method", "responses"}, scenario["required"])
+
+	props := scenario["properties"].(map[string]interface{})
+	assert.Contains(t, props, "circuitBreaker")
+	assert.NotContains(t, props, "source", "Runtime-only fields are not part of the file format")
+	assert.Equal(t, "object", props["state"].(map[string]interface{})["type"], "Exports with state validate")
+	assert.Equal(t, map[string]interface{}{"$ref": "#/$defs/Response"}, props["responses"].(map[string]interface{})["items"])
+
+	response := defs["Response"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, "string", response["delay"].(map[string]interface{})["type"])
+	assert.Contains(t, response, "delayRange")
+	assert.Contains(t, defs, "MatchConfig")
+
+	// matches.body also takes the legacy structured form the loader accepts
+	match := defs["MatchConfig"].(map[string]interface{})["properties"].(map[string]interface{})
+	body := match["body"].(map[string]interface{})["oneOf"].([]interface{})
+	require.Len(t, body, 3)
+	legacy := body[2].(map[string]interface{})
+	assert.Equal(t, []string{"object", "array"}, legacy["type"])
+	assert.Len(t, legacy["not"].(map[string]interface{})["anyOf"], 4, "Mappings with an operator key are the operator form")
+}
+
+func TestLoadScenarios_Strict(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	writeFile(t, file, `port: "9090"
+scenarios:
+  - path: /api/strict
+    method: GET
+    matches:
+      header:
+        X-Test: "1"
+    responses:
+      - status: 200
+        delayrange: 100ms-200ms
+        headers:
+          X-Anything: allowed
+typo: true
+`)
+
+	// Unknown fields are ignored unless strict
+	_, _, err := loadScenarios(false, file)
+	require.NoError(t, err)
+
+	_, _, err = loadScenarios(true, file)
+	require.Error(t, err)
+	problems := err.(*ValidationError).Problems
+	require.Len(t, problems, 3)
+	assert.Equal(t, file+`:6:7: unknown field "matches.header"`, problems[1].String())
+	assert.Equal(t, file+`:10:9: unknown field "responses[0].delayrange" (did you mean "delayRange"?)`, problems[2].String())
+	assert.Equal(t, file+`:13:1: unknown field "typo"`, problems[0].String())
+}
+
+func TestLoadScenarios_StrictExample(t *testing.T) {
+	// The bundled example must stay valid in strict mode
+	_, _, err := loadScenarios(true, "../../scenarios.yaml")
+	assert.NoError(t, err)
+}
+
+func TestLoadScenarios_StrictCombinators(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	writeFile(t, file, `- path: /api/strict
+  method: GET
+  matches:
+    anyOf:
+      - headers: {X-A: "1"}
+      - qurey: {b: "2"}
+    not:
+      header: {X-Canary: {present: true}}
+  responses:
+    - status: 200
+`)
+
+	_, _, err := loadScenarios(true, file)
+	require.Error(t, err)
+	problems := err.(*ValidationError).Problems
+	require.Len(t, problems, 4)
+	assert.Equal(t, file+`:6:9: unknown field "matches.anyOf[1].qurey"`, problems[0].String())
+	assert.Equal(t, file+`:8:7: unknown field "matches.not.header"`, problems[1].String())
+	assert.Equal(t, file+`:6:9: GET /api/strict: matches.anyOf[1] has no matchers`, problems[2].String(), "Misspelled blocks are empty")
+	assert.Equal(t, file+`:8:7: GET /api/strict: matches.not has no matchers`, problems[3].String())
+}
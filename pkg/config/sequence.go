@@ -0,0 +1,183 @@
+package config
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// SequenceKey names the request value that gives each client its own sequence through a
+// scenario's responses, with its own circuit breaker. Exactly one source is set:
+//
+//	sequenceKey:
+//	  header: X-Test-Shard
+//
+// Requests without the value share the scenario's own sequence.
+type SequenceKey struct {
+	Header  string `yaml:"header,omitempty" json:"header,omitempty"`
+	Cookie  string `yaml:"cookie,omitempty" json:"cookie,omitempty"`
+	Query   string `yaml:"query,omitempty" json:"query,omitempty"`
+	PathVar string `yaml:"pathVar,omitempty" json:"pathVar,omitempty"` // A variable of the scenario path
+}
+
+// IsZero reports whether no sequence key is set
+func (k SequenceKey) IsZero() bool {
+	return k == SequenceKey{}
+}
+
+// sources returns the names of the sources that are set
+func (k SequenceKey) sources() []string {
+	var sources []string
+	for _, source := range []struct{ name, value string }{
+		{"header", k.Header}, {"cookie", k.Cookie}, {"query", k.Query}, {"pathVar", k.PathVar},
+	} {
+		if source.value != "" {
+			sources = append(sources, source.name)
+		}
+	}
+	return sources
+}
+
+// SequenceState is the runtime state of one sequence through a scenario's responses
+type SequenceState struct {
+	Index   int32 // Next response (atomic operations)
+	CBState *CircuitBreakerState
+	Shuffle *ShuffleState
+}
+
+func newSequenceState() SequenceState {
+	return SequenceState{CBState: &CircuitBreakerState{State: "closed"}, Shuffle: &ShuffleState{}}
+}
+
+// ScenarioRuntime is the runtime state of a live scenario. Scenarios hold it by pointer, so
+// copying a scenario copies its definition without reading counters that requests update.
+type ScenarioRuntime struct {
+	Hits          uint64 // Requests matched (atomic operations), first for 64-bit alignment
+	SequenceState        // The scenario's own sequence, used by requests without a sequenceKey value
+	Sequences     Sequences
+}
+
+// NewScenarioRuntime returns the runtime state of a scenario that has not served a request
+func NewScenarioRuntime() *ScenarioRuntime {
+	return &ScenarioRuntime{SequenceState: newSequenceState()}
+}
+
+// MaxSequenceKeys caps the sequences a scenario keeps. Sequence key values come from
+// clients, so once the cap is reached the least recently used sequence is forgotten.
+const MaxSequenceKeys = 1000
+
+// Sequences holds the sequences of a scenario with a sequence key, by key value.
+// It keeps at most MaxSequenceKeys of them.
+type Sequences struct {
+	mutex   sync.Mutex
+	entries map[string]*list.Element // Values are *sequenceEntry
+	recent  list.List                // Most recently used first
+}
+
+type sequenceEntry struct {
+	key   string
+	state *SequenceState
+}
+
+// Get returns the sequence for key, starting a new one on first use
+func (q *Sequences) Get(key string) *SequenceState {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if e, ok := q.entries[key]; ok {
+		q.recent.MoveToFront(e)
+		return e.Value.(*sequenceEntry).state
+	}
+
+	if q.entries == nil {
+		q.entries = make(map[string]*list.Element)
+	}
+	if q.recent.Len() >= MaxSequenceKeys {
+		oldest := q.recent.Back()
+		q.recent.Remove(oldest)
+		delete(q.entries, oldest.Value.(*sequenceEntry).key)
+	}
+	state := newSequenceState()
+	q.entries[key] = q.recent.PushFront(&sequenceEntry{key: key, state: &state})
+	return &state
+}
+
+// Lookup returns the sequence for key without starting one or marking it as used
+func (q *Sequences) Lookup(key string) (*SequenceState, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if e, ok := q.entries[key]; ok {
+		return e.Value.(*sequenceEntry).state, true
+	}
+	return nil, false
+}
+
+// Reset forgets the sequence for key, so its next request starts over. It reports whether
+// the key had a sequence.
+func (q *Sequences) Reset(key string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	e, ok := q.entries[key]
+	if ok {
+		q.recent.Remove(e)
+		delete(q.entries, key)
+	}
+	return ok
+}
+
+// Clear forgets every sequence
+func (q *Sequences) Clear() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.entries = nil
+	q.recent.Init()
+}
+
+// Keys returns the key values that have a sequence, sorted
+func (q *Sequences) Keys() []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	keys := make([]string, 0, len(q.entries))
+	for key := range q.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// initRuntimeState creates the runtime state a stored scenario needs, keeping existing state
+func initRuntimeState(s *Scenario) {
+	if s.Runtime == nil {
+		s.Runtime = NewScenarioRuntime()
+	}
+}
+
+// ResetSequence rewinds a live scenario to its first response and closes its circuit
+// breaker. With a key, only the sequence of that sequenceKey value is reset; otherwise the
+// scenario's own sequence and every keyed sequence are. It reports whether the scenario, and
+// with a key the sequence of that value, exists.
+func ResetSequence(id, key string) bool {
+	s, ok := GetScenario(id)
+	if !ok {
+		return false
+	}
+	rt := s.Runtime
+	if rt == nil {
+		return key == ""
+	}
+	if key != "" {
+		return rt.Sequences.Reset(key)
+	}
+
+	atomic.StoreInt32(&rt.Index, 0)
+	cb := rt.CBState
+	cb.Mutex.Lock()
+	cb.State, cb.Failures, cb.Successes = "closed", 0, 0
+	cb.Mutex.Unlock()
+	sh := rt.Shuffle
+	sh.Mutex.Lock()
+	sh.Order = nil
+	sh.Mutex.Unlock()
+	rt.Sequences.Clear()
+	return true
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequences(t *testing.T) {
+	var q Sequences
+	a := q.Get("shard-a")
+	assert.Same(t, a, q.Get("shard-a"), "A key keeps its sequence")
+	assert.Equal(t, "closed", a.CBState.State)
+	q.Get("shard-b")
+	assert.Equal(t, []string{"shard-a", "shard-b"}, q.Keys())
+
+	assert.True(t, q.Reset("shard-a"))
+	assert.False(t, q.Reset("shard-a"))
+	assert.NotSame(t, a, q.Get("shard-a"), "A reset key starts over")
+
+	q.Clear()
+	assert.Empty(t, q.Keys())
+}
+
+func TestSequences_EvictsLeastRecentlyUsed(t *testing.T) {
+	var q Sequences
+	first := q.Get("key-0")
+	for i := 1; i < MaxSequenceKeys; i++ {
+		q.Get("key-" + strconv.Itoa(i))
+	}
+	q.Get("key-0") // Used again, so key-1 is now the least recently used
+	require.Len(t, q.Keys(), MaxSequenceKeys)
+
+	q.Get("new")
+	assert.Len(t, q.Keys(), MaxSequenceKeys, "The number of sequences is capped")
+	_, ok := q.Lookup("key-1")
+	assert.False(t, ok, "The least recently used sequence is evicted")
+	assert.Same(t, first, q.Get("key-0"))
+}
+
+func TestResetSequence(t *testing.T) {
+	s := AddScenario(&Scenario{ID: "reset-seq", Path: "/reset/seq", Method: "GET",
+		SequenceKey: SequenceKey{Header: "X-Shard"},
+		Responses:   []Response{{Status: 503}, {Status: 200}}})
+	s.Runtime.Index = 1
+	s.Runtime.CBState.State = "open"
+	s.Runtime.Shuffle.Order = []int{1}
+	atomic.StoreInt32(&s.Runtime.Sequences.Get("a").Index, 1)
+	atomic.StoreInt32(&s.Runtime.Sequences.Get("b").Index, 1)
+
+	require.True(t, ResetSequence("reset-seq", "a"))
+	assert.Equal(t, []string{"b"}, s.Runtime.Sequences.Keys(), "Only the given key is reset")
+	assert.Equal(t, int32(1), s.Runtime.Index)
+	assert.False(t, ResetSequence("reset-seq", "a"), "Already reset")
+	assert.False(t, ResetSequence("reset-seq", "unknown"))
+
+	require.True(t, ResetSequence("reset-seq", ""))
+	assert.Equal(t, int32(0), s.Runtime.Index)
+	assert.Equal(t, "closed", s.Runtime.CBState.State)
+	assert.Empty(t, s.Runtime.Shuffle.Order)
+	assert.Empty(t, s.Runtime.Sequences.Keys())
+
+	assert.False(t, ResetSequence("missing", ""))
+}
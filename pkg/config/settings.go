@@ -0,0 +1,179 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// setting maps a Config field to its environment variable and command-line flag
+type setting struct {
+	env    string
+	flag   string
+	usage  string
+	isBool bool
+	set    func(c *Config, value string) error
+}
+
+func stringSetting(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func boolSetting(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		val, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		*field(c) = val
+		return nil
+	}
+}
+
+func intSetting(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		val, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*field(c) = val
+		return nil
+	}
+}
+
+func int64Setting(field func(*Config) *int64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		val, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*field(c) = val
+		return nil
+	}
+}
+
+func floatSetting(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		val, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*field(c) = val
+		return nil
+	}
+}
+
+func durationSetting(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		val, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration (e.g. 100ms)", value)
+		}
+		*field(c) = val
+		return nil
+	}
+}
+
+// settings lists every server setting that can be overridden by env vars and flags
+var settings = []setting{
+	{env: "PORT", flag: "port", usage: "Server port", set: stringSetting(func(c *Config) *string { return &c.Port })},
+	{env: "ENABLE_TLS", flag: "enable-tls", usage: "Enable HTTPS", isBool: true, set: boolSetting(func(c *Config) *bool { return &c.EnableTLS })},
+	{env: "CERT_FILE", flag: "cert-file", usage: "TLS certificate file", set: stringSetting(func(c *Config) *string { return &c.CertFile })},
+	{env: "KEY_FILE", flag: "key-file", usage: "TLS key file", set: stringSetting(func(c *Config) *string { return &c.KeyFile })},
+	{env: "CLIENT_CA_FILE", flag: "client-ca-file", usage: "CA bundle for verifying TLS client certificates", set: stringSetting(func(c *Config) *string { return &c.ClientCAFile })},
+	{env: "ENABLE_CORS", flag: "enable-cors", usage: "Enable CORS for all origins", isBool: true, set: boolSetting(func(c *Config) *bool { return &c.EnableCORS })},
+	{env: "LOG_REQUESTS", flag: "log-requests", usage: "Log each request to stdout", isBool: true, set: boolSetting(func(c *Config) *bool { return &c.LogRequests })},
+	{env: "LOG_HEADERS", flag: "log-headers", usage: "Log request headers", isBool: true, set: boolSetting(func(c *Config) *bool { return &c.LogHeaders })},
+	{env: "LOG_BODY", flag: "log-body", usage: "Log request bodies", isBool: true, set: boolSetting(func(c *Config) *bool { return &c.LogBody })},
+	{env: "MAX_BODY_SIZE", flag: "max-body-size", usage: "Max request body size in bytes", set: int64Setting(func(c *Config) *int64 { return &c.MaxBodySize })},
+	{env: "HOSTNAME", flag: "hostname", usage: "Hostname reported by the server", set: stringSetting(func(c *Config) *string { return &c.Hostname })},
+	{env: "RATE_LIMIT_RPS", flag: "rate-limit-rps", usage: "Requests per second limit, 0 disables", set: floatSetting(func(c *Config) *float64 { return &c.RateLimitPerS })},
+	{env: "HISTORY_SIZE", flag: "history-size", usage: "Number of requests to keep in history", set: intSetting(func(c *Config) *int { return &c.HistorySize })},
+	{env: "ECHO_DELAY", flag: "echo-delay", usage: "Global delay for echo requests (e.g. 100ms)", set: durationSetting(func(c *Config) *time.Duration { return &c.GlobalDelay })},
+	{env: "ECHO_CHAOS_PROBABILITY", flag: "echo-chaos-probability", usage: "Probability (0.0-1.0) of random 500 errors", set: floatSetting(func(c *Config) *float64 { return &c.GlobalChaosProbability })},
+	{env: "SCENARIOS_PATH", flag: "scenarios", usage: "Scenario file or directory", set: stringSetting(func(c *Config) *string { return &c.ScenariosPath })},
+	{env: "STRICT_SCENARIOS", flag: "strict", usage: "Reject unknown fields in scenario files", isBool: true, set: boolSetting(func(c *Config) *bool { return &c.StrictScenarios })},
+	{env: "SCENARIO_PROFILE", flag: "profile", usage: "Scenario profile to activate at startup", set: stringSetting(func(c *Config) *string { return &c.Profile })},
+	{env: "SCENARIO_RELOAD_INTERVAL", flag: "reload-interval", usage: "Scenario file poll interval, 0 disables hot reload", set: durationSetting(func(c *Config) *time.Duration { return &c.ReloadInterval })},
+}
+
+// settingFlag is a flag.Value that records the raw value; it is parsed when the config is loaded
+type settingFlag struct {
+	value  string
+	isBool bool
+}
+
+func (f *settingFlag) String() string     { return f.value }
+func (f *settingFlag) Set(v string) error { f.value = v; return nil }
+func (f *settingFlag) IsBoolFlag() bool   { return f.isBool }
+
+// BindFlags registers a flag for every server setting on fs.
+// Pass the parsed FlagSet to Load so explicitly set flags override env vars and the config file.
+func BindFlags(fs *flag.FlagSet) {
+	for _, s := range settings {
+		fs.Var(&settingFlag{isBool: s.isBool}, s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+}
+
+// applySettings applies env vars and then explicitly set flags on top of c
+func applySettings(c *Config, lookupEnv func(string) (string, bool), fs *flag.FlagSet) error {
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok && value != "" {
+			if err := s.set(c, value); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", s.env, err)
+			}
+		}
+	}
+
+	if fs == nil {
+		return nil
+	}
+	byFlag := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byFlag[s.flag] = s
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		s, ok := byFlag[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		if setErr := s.set(c, f.Value.String()); setErr != nil {
+			err = fmt.Errorf("invalid value for --%s: %w", s.flag, setErr)
+		}
+	})
+	return err
+}
+
+// validateConfig checks that the merged server settings are usable
+func validateConfig(c *Config) error {
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %q: must be a number between 1 and 65535", c.Port)
+	}
+	if c.EnableTLS && (c.CertFile == "" || c.KeyFile == "") {
+		return fmt.Errorf("TLS is enabled but certFile or keyFile is empty")
+	}
+	if c.MaxBodySize <= 0 {
+		return fmt.Errorf("invalid maxBodySize %d: must be positive", c.MaxBodySize)
+	}
+	if c.RateLimitPerS < 0 {
+		return fmt.Errorf("invalid rateLimitPerS %g: must not be negative", c.RateLimitPerS)
+	}
+	if c.HistorySize < 1 {
+		return fmt.Errorf("invalid historySize %d: must be at least 1", c.HistorySize)
+	}
+	if c.GlobalDelay < 0 {
+		return fmt.Errorf("invalid globalDelay %s: must not be negative", c.GlobalDelay)
+	}
+	if c.GlobalChaosProbability < 0 || c.GlobalChaosProbability > 1 {
+		return fmt.Errorf("invalid globalChaosProbability %g: must be between 0 and 1", c.GlobalChaosProbability)
+	}
+	if c.ReloadInterval < 0 {
+		return fmt.Errorf("invalid reloadInterval %s: must not be negative", c.ReloadInterval)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Precedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, file, `
+port: "9000"
+historySize: 10
+globalDelay: 20ms
+logBody: false
+scenarios:
+  - path: /from-config-file
+    method: GET
+    responses:
+      - status: 200
+`)
+	t.Setenv("HISTORY_SIZE", "20")
+	t.Setenv("ECHO_DELAY", "30ms")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	BindFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--echo-delay", "40ms", "--log-body", "--scenarios", filepath.Join(t.TempDir(), "missing.yaml")}))
+
+	cfg, err := Load(LoadOptions{ConfigFile: file, Flags: fs})
+	require.NoError(t, err)
+
+	assert.Equal(t, "9000", cfg.Port, "File should override defaults")
+	assert.Equal(t, 20, cfg.HistorySize, "Env should override file")
+	assert.Equal(t, 40*time.Millisecond, cfg.GlobalDelay, "Flags should override env")
+	assert.True(t, cfg.LogBody, "Boolean flags should not need a value")
+	assert.Equal(t, DefaultConfig.KeyFile, cfg.KeyFile, "Unset values should keep defaults")
+
+	_, ok := GetScenarios().Load("/from-config-file_GET")
+	assert.True(t, ok, "Scenarios from the config file should be loaded")
+}
+
+func TestLoad_InvalidValues(t *testing.T) {
+	t.Run("InvalidEnv", func(t *testing.T) {
+		t.Setenv("RATE_LIMIT_RPS", "fast")
+		_, err := LoadConfig("non_existent_file.yaml")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "RATE_LIMIT_RPS")
+	})
+
+	t.Run("InvalidFlag", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		BindFlags(fs)
+		require.NoError(t, fs.Parse([]string{"--history-size=lots"}))
+		_, err := Load(LoadOptions{Flags: fs})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--history-size")
+	})
+
+	t.Run("OutOfRange", func(t *testing.T) {
+		t.Setenv("ECHO_CHAOS_PROBABILITY", "1.5")
+		_, err := LoadConfig("non_existent_file.yaml")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "globalChaosProbability")
+	})
+
+	t.Run("InvalidConfigFile", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "config.yaml")
+		writeFile(t, file, "historySize: many\n")
+		_, err := Load(LoadOptions{ConfigFile: file})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), file)
+	})
+}
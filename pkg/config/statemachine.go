@@ -0,0 +1,85 @@
+package config
+
+import "sync"
+
+const (
+	// DefaultStateMachine is the state machine of scenarios that do not name one
+	DefaultStateMachine = "default"
+	// InitialState is the state of a state machine before any transition, and after a reset
+	InitialState = "initial"
+)
+
+var (
+	machinesMutex sync.RWMutex
+	machines      = map[string]string{} // State machine name to current state, if not initial
+)
+
+// StateMachineName returns the state machine a scenario's whenState and setState refer to
+func (s *Scenario) StateMachineName() string {
+	if s.StateMachine != "" {
+		return s.StateMachine
+	}
+	return DefaultStateMachine
+}
+
+// MachineState returns the current state of a state machine
+func MachineState(name string) string {
+	machinesMutex.RLock()
+	defer machinesMutex.RUnlock()
+	if state, ok := machines[name]; ok {
+		return state
+	}
+	return InitialState
+}
+
+// SetMachineState moves a state machine to state
+func SetMachineState(name, state string) {
+	machinesMutex.Lock()
+	defer machinesMutex.Unlock()
+	if state == InitialState {
+		delete(machines, name)
+		return
+	}
+	machines[name] = state
+}
+
+// MachineStates returns the current state of every state machine that live scenarios refer
+// to or that has left its initial state
+func MachineStates() map[string]string {
+	states := make(map[string]string)
+	for _, s := range ListScenarios() {
+		if s.StateMachine != "" || s.WhenState != "" || s.SetState != "" || responsesSetState(s) {
+			states[s.StateMachineName()] = InitialState
+		}
+	}
+
+	machinesMutex.RLock()
+	defer machinesMutex.RUnlock()
+	for name, state := range machines {
+		states[name] = state
+	}
+	return states
+}
+
+func responsesSetState(s *Scenario) bool {
+	for i := range s.Responses {
+		if s.Responses[i].SetState != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetMachineStates moves the named state machines, or all of them if none are named, back
+// to their initial state
+func ResetMachineStates(names ...string) {
+	machinesMutex.Lock()
+	defer machinesMutex.Unlock()
+	if len(names) == 0 {
+		clear(machines)
+		return
+	}
+	for _, name := range names {
+		delete(machines, name)
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMachineStates(t *testing.T) {
+	AddScenario(&Scenario{Path: "/machines/orders", Method: "POST", StateMachine: "machines-orders", SetState: "created", Responses: []Response{{Status: 201}}})
+	AddScenario(&Scenario{Path: "/machines/login", Method: "POST", StateMachine: "machines-login", Responses: []Response{{Status: 401, SetState: "locked"}}})
+
+	assert.Equal(t, InitialState, MachineState("machines-orders"))
+	states := MachineStates()
+	assert.Equal(t, InitialState, states["machines-orders"], "Machines referenced by scenarios are listed")
+	assert.Equal(t, InitialState, states["machines-login"])
+
+	SetMachineState("machines-orders", "created")
+	SetMachineState("machines-adhoc", "ready")
+	assert.Equal(t, "created", MachineState("machines-orders"))
+	assert.Equal(t, "ready", MachineStates()["machines-adhoc"])
+
+	ResetMachineStates("machines-orders")
+	assert.Equal(t, InitialState, MachineState("machines-orders"))
+	assert.Equal(t, "ready", MachineState("machines-adhoc"))
+
+	ResetMachineStates()
+	assert.Equal(t, InitialState, MachineState("machines-adhoc"))
+	_, listed := MachineStates()["machines-adhoc"]
+	assert.False(t, listed, "Unreferenced machines disappear once reset")
+}
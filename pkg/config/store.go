@@ -0,0 +1,297 @@
+package config
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// scenarioSeq generates IDs for scenarios that do not declare one
+var scenarioSeq uint64
+
+func nextScenarioID() string {
+	return "s" + strconv.FormatUint(atomic.AddUint64(&scenarioSeq, 1), 10)
+}
+
+// nextFreeScenarioID generates an ID that inUse does not report as taken, so generated IDs
+// never clash with IDs declared in files or carried over from an export
+func nextFreeScenarioID(inUse func(id string) bool) string {
+	for {
+		if id := nextScenarioID(); !inUse(id) {
+			return id
+		}
+	}
+}
+
+func scenarioKey(s *Scenario) string {
+	return s.Path + "_" + s.Method
+}
+
+// ScenarioOrder lists the live scenarios in the order they were loaded or added. Every change
+// to the live set publishes a new ScenarioOrder, so one can be used as a cache key; it is
+// never modified.
+type ScenarioOrder struct {
+	Scenarios []*Scenario
+}
+
+// LiveScenarioOrder returns the current order of the live scenarios
+func LiveScenarioOrder() *ScenarioOrder {
+	return scenarioOrder.Load()
+}
+
+// setScenarioOrderLocked publishes a new order (caller must hold configLock)
+func setScenarioOrderLocked(list []*Scenario) {
+	scenarioOrder.Store(&ScenarioOrder{Scenarios: list})
+}
+
+// AddScenario adds a scenario to the live set and returns the stored scenario.
+// A scenario with the ID of an existing one replaces it; a scenario without an ID that is
+// identical to an existing one is not added twice (the existing one is returned).
+func AddScenario(s *Scenario) *Scenario {
+	configLock.Lock()
+	defer configLock.Unlock()
+	stored, _ := addScenarioLocked(s)
+	return stored
+}
+
+// PutScenario stores s under id, replacing any scenario with that ID.
+// It reports whether a new scenario was created.
+func PutScenario(id string, s *Scenario) (*Scenario, bool) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	s.ID = id
+	return addScenarioLocked(s)
+}
+
+// ErrScenarioNotFound is returned by PatchScenario for an unknown ID
+var ErrScenarioNotFound = errors.New("scenario not found")
+
+// PatchScenario applies patch to a copy of the definition of the live scenario with the given
+// ID and stores the result as PutScenario does. The lookup, patch and store all hold configLock,
+// so concurrent changes to the scenario are not lost. Nothing is stored if patch fails.
+func PatchScenario(id string, patch func(*Scenario) error) (*Scenario, error) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	existing := findScenarioLocked(scenarios.Load(), id)
+	if existing == nil {
+		return nil, ErrScenarioNotFound
+	}
+	s := existing.Definition()
+	if err := patch(&s); err != nil {
+		return nil, err
+	}
+	s.ID = id
+	stored, _ := addScenarioLocked(&s)
+	return stored, nil
+}
+
+// addScenarioLocked adds or replaces a scenario (caller must hold configLock)
+func addScenarioLocked(s *Scenario) (*Scenario, bool) {
+	m := scenarios.Load()
+
+	if s.ID != "" {
+		if existing := findScenarioLocked(m, s.ID); existing != nil {
+			if fingerprint(existing) == fingerprint(s) {
+				// Nothing changed, keep the runtime state
+				return existing, false
+			}
+			replaceScenarioLocked(m, existing, s)
+			return s, false
+		}
+	} else {
+		fp := fingerprint(s)
+		var duplicate *Scenario
+		m.Range(func(_, value interface{}) bool {
+			for _, existing := range value.([]*Scenario) {
+				if fingerprint(existing) == fp {
+					duplicate = existing
+					return false
+				}
+			}
+			return true
+		})
+		if duplicate != nil {
+			return duplicate, false
+		}
+		s.ID = nextFreeScenarioID(func(id string) bool {
+			return findScenarioLocked(m, id) != nil
+		})
+	}
+
+	initRuntimeState(s)
+	storeScenario(m, s)
+	order := LiveScenarioOrder().Scenarios
+	setScenarioOrderLocked(append(order[:len(order):len(order)], s))
+	return s, true
+}
+
+// replaceScenarioLocked swaps old for s, keeping its position when the path and method are
+// unchanged. The replacement keeps old's origin and profile, so a later file reload still wins.
+func replaceScenarioLocked(m *sync.Map, old, s *Scenario) {
+	s.Runtime = NewScenarioRuntime()
+	s.Source = old.Source
+	s.Profile = old.Profile
+
+	if scenarioKey(old) == scenarioKey(s) {
+		list := loadScenarioList(m, scenarioKey(s))
+		newList := make([]*Scenario, len(list))
+		for i, existing := range list {
+			if existing == old {
+				newList[i] = s
+			} else {
+				newList[i] = existing
+			}
+		}
+		m.Store(scenarioKey(s), newList)
+	} else {
+		removeScenario(m, old)
+		storeScenario(m, s)
+	}
+
+	order := append([]*Scenario(nil), LiveScenarioOrder().Scenarios...)
+	for i, existing := range order {
+		if existing == old {
+			order[i] = s
+		}
+	}
+	setScenarioOrderLocked(order)
+
+	for i, f := range fileScenarios {
+		if f == old {
+			fileScenarios[i] = s
+			updateDefinitionLocked(old.ID, s)
+		}
+	}
+}
+
+// updateDefinitionLocked applies an admin API change of a live file scenario to its loaded
+// definition, so the change survives profile switches. A nil s removes the definition.
+// Caller must hold configLock.
+func updateDefinitionLocked(id string, s *Scenario) {
+	for i := range loadedScenarios {
+		if loadedScenarios[i].ID != id {
+			continue
+		}
+		if s == nil {
+			loadedScenarios = append(loadedScenarios[:i:i], loadedScenarios[i+1:]...)
+		} else {
+			loadedScenarios[i] = s.Definition()
+		}
+		return
+	}
+}
+
+// DeleteScenario removes the scenario with the given ID and reports whether it existed
+func DeleteScenario(id string) bool {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	m := scenarios.Load()
+	existing := findScenarioLocked(m, id)
+	if existing == nil {
+		return false
+	}
+	removeScenario(m, existing)
+
+	order := LiveScenarioOrder().Scenarios
+	kept := make([]*Scenario, 0, len(order))
+	for _, s := range order {
+		if s != existing {
+			kept = append(kept, s)
+		}
+	}
+	setScenarioOrderLocked(kept)
+
+	for i, f := range fileScenarios {
+		if f == existing {
+			fileScenarios = append(fileScenarios[:i:i], fileScenarios[i+1:]...)
+			updateDefinitionLocked(id, nil)
+			break
+		}
+	}
+	return true
+}
+
+// GetScenario returns the live scenario with the given ID
+func GetScenario(id string) (*Scenario, bool) {
+	s := findScenarioLocked(scenarios.Load(), id)
+	return s, s != nil
+}
+
+// ListScenarios returns every live scenario ordered by path and method.
+// Scenarios for the same path and method keep their evaluation order.
+func ListScenarios() []*Scenario {
+	var keys []string
+	m := scenarios.Load()
+	m.Range(func(key, _ interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	sort.Strings(keys)
+
+	var list []*Scenario
+	for _, key := range keys {
+		list = append(list, loadScenarioList(m, key)...)
+	}
+	return list
+}
+
+// findScenarioLocked looks up a scenario by ID. Reads are safe without the lock since
+// lists are copy-on-write, but callers that modify the set must hold configLock.
+func findScenarioLocked(m *sync.Map, id string) *Scenario {
+	var found *Scenario
+	m.Range(func(_, value interface{}) bool {
+		for _, s := range value.([]*Scenario) {
+			if s.ID == id {
+				found = s
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
+
+func loadScenarioList(m *sync.Map, key string) []*Scenario {
+	if v, ok := m.Load(key); ok {
+		return v.([]*Scenario)
+	}
+	return nil
+}
+
+// storeScenario appends a scenario to the list stored under its path_method key in m
+func storeScenario(m *sync.Map, s *Scenario) {
+	key := scenarioKey(s)
+
+	// Load existing list or create new
+	if v, ok := m.Load(key); ok {
+		oldList := v.([]*Scenario)
+		// Copy-On-Write: Create a new slice to avoid race conditions with readers
+		// who might be iterating over the old slice.
+		newList := make([]*Scenario, len(oldList)+1)
+		copy(newList, oldList)
+		newList[len(oldList)] = s
+		m.Store(key, newList)
+	} else {
+		m.Store(key, []*Scenario{s})
+	}
+}
+
+// removeScenario removes s from its path_method list in m (copy-on-write)
+func removeScenario(m *sync.Map, s *Scenario) {
+	key := scenarioKey(s)
+	list := loadScenarioList(m, key)
+	newList := make([]*Scenario, 0, len(list))
+	for _, existing := range list {
+		if existing != s {
+			newList = append(newList, existing)
+		}
+	}
+	if len(newList) == 0 {
+		m.Delete(key)
+	} else {
+		m.Store(key, newList)
+	}
+}
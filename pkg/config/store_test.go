@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenarioStore(t *testing.T) {
+	first := AddScenario(&Scenario{Path: "/store", Method: "GET", Responses: []Response{{Status: 200}}})
+	require.NotEmpty(t, first.ID, "An ID should be generated")
+
+	// Posting the same scenario twice does not stack duplicates
+	again := AddScenario(&Scenario{Path: "/store", Method: "GET", Responses: []Response{{Status: 200}}})
+	assert.Same(t, first, again)
+	assert.Len(t, loadScenarioList(GetScenarios(), "/store_GET"), 1)
+
+	// A scenario with an existing ID replaces it in place
+	second := AddScenario(&Scenario{Path: "/store", Method: "GET", Matches: MatchConfig{Query: map[string]StringMatcher{"a": {Equals: "b"}}}, Responses: []Response{{Status: 201}}})
+	replacement := AddScenario(&Scenario{ID: first.ID, Path: "/store", Method: "GET", Responses: []Response{{Status: 503}}})
+	list := loadScenarioList(GetScenarios(), "/store_GET")
+	require.Len(t, list, 2)
+	assert.Same(t, replacement, list[0], "Replacement should keep its evaluation position")
+	assert.Same(t, second, list[1])
+
+	got, ok := GetScenario(first.ID)
+	require.True(t, ok)
+	assert.Equal(t, 503, got.Responses[0].Status)
+
+	// Moving a scenario to another path re-keys it
+	_, created := PutScenario(first.ID, &Scenario{Path: "/store/moved", Method: "GET", Responses: []Response{{Status: 200}}})
+	assert.False(t, created)
+	assert.Len(t, loadScenarioList(GetScenarios(), "/store_GET"), 1)
+	assert.Len(t, loadScenarioList(GetScenarios(), "/store/moved_GET"), 1)
+
+	_, created = PutScenario("store-new", &Scenario{Path: "/store", Method: "POST", Responses: []Response{{Status: 201}}})
+	assert.True(t, created)
+
+	assert.True(t, DeleteScenario(first.ID))
+	assert.False(t, DeleteScenario(first.ID))
+	_, ok = GetScenarios().Load("/store/moved_GET")
+	assert.False(t, ok, "Empty lists should be removed")
+
+	ids := make(map[string]bool)
+	for _, s := range ListScenarios() {
+		ids[s.ID] = true
+	}
+	assert.True(t, ids["store-new"])
+	assert.True(t, ids[second.ID])
+}
@@ -0,0 +1,582 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arun0009/go-resilience-mock/pkg/jsonpath"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem describes a single scenario validation failure and where it was found
+type Problem struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	switch {
+	case p.File != "" && p.Line > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+	case p.File != "":
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	case p.Line > 0:
+		return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message)
+	default:
+		return p.Message
+	}
+}
+
+// ValidationError collects every problem found while validating scenarios
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = p.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validMethods lists the HTTP methods a scenario may be registered for
+var validMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodConnect: true,
+	http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// validID matches scenario IDs that can be used as a single URL path segment
+var validID = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+
+// ValidateScenario checks a scenario for mistakes that would otherwise only show up at
+// request time (or never). node is the scenario's YAML node and is used to report the line
+// and column of each problem; it may be nil when no source position is known.
+func ValidateScenario(s *Scenario, file string, node *yaml.Node) []Problem {
+	v := &scenarioValidator{file: file, scenario: node}
+	v.validate(s)
+	return v.problems
+}
+
+// ValidateResource checks a resource declaration like ValidateScenario checks a scenario
+func ValidateResource(r *Resource, file string, node *yaml.Node) []Problem {
+	v := &scenarioValidator{file: file, scenario: node}
+	v.validateResource(r)
+	return v.problems
+}
+
+// ValidateAdminScenario checks a scenario received through the admin API for fields only
+// scenario files may set: a bodyFile would let any client read files the server can read,
+// and resource scenarios are generated from resource declarations. Run it before
+// ValidateScenario, which looks body files up.
+func ValidateAdminScenario(s *Scenario, node *yaml.Node) []Problem {
+	v := &scenarioValidator{scenario: node}
+	if s.Method != "" || s.Path != "" {
+		v.prefix = strings.TrimSpace(s.Method+" "+s.Path) + ": "
+	}
+	if s.Resource != "" {
+		v.report(at(field(node, "resource")), "resource may only be set by a resource declaration in a scenario file")
+	}
+	responsesNode := field(node, "responses")
+	for i := range s.Responses {
+		if s.Responses[i].BodyFile != "" {
+			v.report(at(field(item(responsesNode, i), "bodyFile")), "responses[%d].bodyFile may only be set in scenario files (use body)", i)
+		}
+	}
+	return v.problems
+}
+
+// ValidateScenarioFiles loads and validates scenario files or directories without
+// touching the live scenario set. Every problem found is reported in a *ValidationError.
+// In strict mode, unknown fields are reported too.
+func ValidateScenarioFiles(strict bool, paths ...string) ([]Scenario, error) {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+	scenarios, _, err := loadScenarios(strict, paths...)
+	return scenarios, err
+}
+
+// scenarioValidator accumulates problems for a single scenario
+type scenarioValidator struct {
+	file     string
+	scenario *yaml.Node
+	prefix   string
+	problems []Problem
+}
+
+// report records a problem at the first non-nil node in at, falling back to the scenario node
+func (v *scenarioValidator) report(at []*yaml.Node, format string, args ...interface{}) {
+	p := Problem{File: v.file, Message: v.prefix + fmt.Sprintf(format, args...)}
+	for _, n := range append(at, v.scenario) {
+		if n != nil {
+			p.Line, p.Column = n.Line, n.Column
+			break
+		}
+	}
+	v.problems = append(v.problems, p)
+}
+
+func at(nodes ...*yaml.Node) []*yaml.Node {
+	return nodes
+}
+
+func (v *scenarioValidator) validate(s *Scenario) {
+	if s.Method != "" || s.Path != "" {
+		v.prefix = strings.TrimSpace(s.Method+" "+s.Path) + ": "
+	}
+
+	if s.ID != "" && !validID.MatchString(s.ID) {
+		v.report(at(field(v.scenario, "id")), "id %q may only contain letters, digits, '.', '_', '~' and '-'", s.ID)
+	}
+
+	switch {
+	case s.Path == "":
+		v.report(nil, "path is required")
+	case !strings.HasPrefix(s.Path, "/"):
+		v.report(at(field(v.scenario, "path")), "path %q must start with /", s.Path)
+	default:
+		if _, err := CompilePathTemplate(s.Path); err != nil {
+			v.report(at(field(v.scenario, "path")), "path: %v", err)
+		}
+	}
+
+	switch {
+	case s.Method == "":
+		v.report(nil, "method is required")
+	case !validMethods[s.Method]:
+		v.report(at(field(v.scenario, "method")), "method %q is not a valid HTTP method (methods are case-sensitive, e.g. GET)", s.Method)
+	}
+
+	v.validateMatches("matches", &s.Matches, field(v.scenario, "matches"))
+
+	responsesNode := field(v.scenario, "responses")
+	switch {
+	case s.Resource != "":
+		if LookupCollection(s.Resource) == nil {
+			v.report(nil, "resource %q is not declared in a scenario file", s.Resource)
+		}
+		if len(s.Responses) > 0 {
+			v.report(at(responsesNode), "responses have no effect on the operations of resource %q (use its faults)", s.Resource)
+		}
+	case len(s.Responses) == 0:
+		v.report(at(responsesNode), "at least one response is required")
+	}
+	for i := range s.Responses {
+		v.validateResponse(fmt.Sprintf("responses[%d]", i), &s.Responses[i], item(responsesNode, i))
+	}
+	v.validateStrategy(s, responsesNode)
+	v.validateSequenceKey(s)
+	if s.StateMachine != "" && s.WhenState == "" && s.SetState == "" && !responsesSetState(s) {
+		v.report(at(field(v.scenario, "stateMachine")), "stateMachine has no effect without whenState or setState")
+	}
+	v.validateCircuitBreaker(s.CircuitBreaker)
+}
+
+func (v *scenarioValidator) validateCircuitBreaker(cb CircuitBreakerConfig) {
+	cbNode := field(v.scenario, "circuitBreaker")
+	if cb.FailureThreshold < 0 {
+		v.report(at(field(cbNode, "failureThreshold"), cbNode), "circuitBreaker.failureThreshold must not be negative")
+	}
+	if cb.SuccessThreshold < 0 {
+		v.report(at(field(cbNode, "successThreshold"), cbNode), "circuitBreaker.successThreshold must not be negative")
+	}
+	if cb.FailureThreshold > 0 && cb.Timeout <= 0 {
+		v.report(at(field(cbNode, "timeout"), cbNode), "circuitBreaker.timeout must be positive when failureThreshold is set")
+	}
+}
+
+func (v *scenarioValidator) validateResource(r *Resource) {
+	v.prefix = strings.TrimSpace("resource "+r.Path) + ": "
+	pathNode := field(v.scenario, "path")
+	switch {
+	case r.Path == "":
+		v.report(nil, "path is required")
+	case !strings.HasPrefix(r.Path, "/"):
+		v.report(at(pathNode), "path %q must start with /", r.Path)
+	case r.Path == "/":
+		v.report(at(pathNode), "path must name a collection, e.g. /api/users, not /")
+	case strings.HasSuffix(r.Path, "/"):
+		v.report(at(pathNode), "path %q must not end with /", r.Path)
+	default:
+		if template, err := CompilePathTemplate(r.Path); err != nil {
+			v.report(at(pathNode), "path: %v", err)
+		} else if len(template.Vars()) > 0 {
+			v.report(at(pathNode), "path %q must not contain path variables", r.Path)
+		}
+	}
+
+	seedNode := field(v.scenario, "seed")
+	idField := r.ItemIDField()
+	seen := make(map[string]bool)
+	for i, raw := range r.Seed {
+		var seedItem map[string]interface{}
+		if json.Unmarshal(raw, &seedItem) != nil || seedItem == nil {
+			v.report(at(item(seedNode, i), seedNode), "seed[%d] must be a mapping", i)
+			continue
+		}
+		value, ok := seedItem[idField]
+		if !ok {
+			continue // Given the next free ID
+		}
+		id, ok := ItemID(value)
+		switch {
+		case !ok:
+			v.report(at(field(item(seedNode, i), idField), item(seedNode, i)), "seed[%d].%s must be a string or an integer", i, idField)
+		case seen[id]:
+			v.report(at(field(item(seedNode, i), idField), item(seedNode, i)), "seed[%d].%s %q is already used by another item", i, idField, id)
+		}
+		seen[id] = true
+	}
+
+	if r.Delay < 0 {
+		v.report(at(field(v.scenario, "delay")), "delay must not be negative")
+	}
+	if r.DelayRange != "" {
+		if _, _, err := ParseDelayRange(r.DelayRange); err != nil {
+			v.report(at(field(v.scenario, "delayRange")), "delayRange %v", err)
+		}
+	}
+	faultsNode := field(v.scenario, "faults")
+	for i := range r.Faults {
+		v.validateResponse(fmt.Sprintf("faults[%d]", i), &r.Faults[i], item(faultsNode, i))
+	}
+	v.validateCircuitBreaker(r.CircuitBreaker)
+}
+
+func (v *scenarioValidator) validateStrategy(s *Scenario, responsesNode *yaml.Node) {
+	if s.Strategy != "" && !slices.Contains(Strategies, s.Strategy) {
+		v.report(at(field(v.scenario, "strategy")), "strategy %q must be one of %s", s.Strategy, strings.Join(Strategies, ", "))
+	}
+	ordered := s.Strategy == "" || s.Strategy == StrategySequential || s.Strategy == StrategyStickyLast
+	var steps int64
+	for i, r := range s.Responses {
+		weightNode := field(item(responsesNode, i), "weight")
+		switch {
+		case r.Weight < 0:
+			v.report(at(weightNode, item(responsesNode, i)), "responses[%d].weight must not be negative", i)
+		case r.Weight > 0 && s.Strategy != StrategyWeighted:
+			v.report(at(weightNode, item(responsesNode, i)), "responses[%d].weight only applies to the %s strategy", i, StrategyWeighted)
+		}
+		timesNode := field(item(responsesNode, i), "times")
+		switch {
+		case r.Times < 0:
+			v.report(at(timesNode, item(responsesNode, i)), "responses[%d].times must not be negative", i)
+		case r.Times > 0 && !ordered:
+			v.report(at(timesNode, item(responsesNode, i)), "responses[%d].times only applies to the %s and %s strategies", i, StrategySequential, StrategyStickyLast)
+		case r.Times > MaxSequenceSteps:
+			v.report(at(timesNode, item(responsesNode, i)), "responses[%d].times must be at most %d", i, MaxSequenceSteps)
+		default:
+			steps += int64(max(r.Times, 1))
+		}
+	}
+	if steps > MaxSequenceSteps {
+		v.report(at(responsesNode), "responses must add up to at most %d steps counting times, got %d", MaxSequenceSteps, steps)
+	}
+
+	onExhaustedNode := field(v.scenario, "onExhausted")
+	switch {
+	case s.OnExhausted == "":
+	case !slices.Contains(ExhaustedBehaviors, s.OnExhausted):
+		v.report(at(onExhaustedNode), "onExhausted %q must be one of %s", s.OnExhausted, strings.Join(ExhaustedBehaviors, ", "))
+	case s.Strategy != "" && s.Strategy != StrategySequential:
+		v.report(at(onExhaustedNode), "onExhausted only applies to the %s strategy", StrategySequential)
+	}
+}
+
+func (v *scenarioValidator) validateSequenceKey(s *Scenario) {
+	if s.SequenceKey.IsZero() {
+		return
+	}
+	keyNode := field(v.scenario, "sequenceKey")
+	if sources := s.SequenceKey.sources(); len(sources) > 1 {
+		v.report(at(keyNode), "sequenceKey sets %s; set exactly one", strings.Join(sources, ", "))
+	}
+	if name := s.SequenceKey.PathVar; name != "" {
+		if template, err := CompilePathTemplate(s.Path); err == nil && !slices.Contains(template.Vars(), name) {
+			v.report(at(field(keyNode, "pathVar"), keyNode), "sequenceKey.pathVar %q is not a variable of path %q", name, s.Path)
+		}
+	}
+}
+
+func (v *scenarioValidator) validateMatches(name string, m *MatchConfig, node *yaml.Node) {
+	v.validateStringMatchers(name+".headers", m.Headers, field(node, "headers"))
+	v.validateStringMatchers(name+".query", m.Query, field(node, "query"))
+	v.validateStringMatchers(name+".cookies", m.Cookies, field(node, "cookies"))
+	for _, sm := range []struct {
+		key string
+		m   StringMatcher
+	}{{"host", m.Host}, {"contentType", m.ContentType}, {"clientCertSubject", m.ClientCertSubject}} {
+		if !sm.m.IsZero() {
+			v.validateStringMatcher(name+"."+sm.key, sm.m, field(node, sm.key))
+		}
+	}
+	ipNode := field(node, "clientIp")
+	for i, ip := range m.ClientIP {
+		if _, err := ParseClientIP(ip); err != nil {
+			v.report(at(item(ipNode, i), ipNode), "%s.clientIp[%d] %q is not an IP address or CIDR range", name, i, ip)
+		}
+	}
+	v.validateJSONMatchers(name, m, node)
+	v.validateStringMatchers(name+".form", m.Form, field(node, "form"))
+	v.validatePartMatchers(name, m.Multipart, field(node, "multipart"))
+	if !m.GraphQL.IsZero() {
+		v.validateGraphQLMatcher(name+".graphql", &m.GraphQL, field(node, "graphql"))
+	}
+
+	if m.Body.Regex != "" {
+		bodyNode := field(node, "body")
+		if _, err := CompileRegex(m.Body.Regex); err != nil {
+			v.report(at(field(bodyNode, "regex"), bodyNode), "%s.body has an invalid regular expression: %v", name, err)
+		}
+	}
+
+	for _, c := range []struct {
+		key    string
+		blocks []MatchConfig
+	}{{"anyOf", m.AnyOf}, {"allOf", m.AllOf}, {"not", m.Not}} {
+		cNode := field(node, c.key)
+		for i := range c.blocks {
+			blockName := fmt.Sprintf("%s.%s[%d]", name, c.key, i)
+			blockNode := item(cNode, i)
+			if cNode != nil && cNode.Kind == yaml.MappingNode {
+				blockName, blockNode = name+"."+c.key, cNode // not written as a single block
+			}
+			if c.blocks[i].Count() == 0 {
+				v.report(at(blockNode, cNode), "%s has no matchers", blockName)
+			}
+			v.validateMatches(blockName, &c.blocks[i], blockNode)
+		}
+	}
+}
+
+func (v *scenarioValidator) validateStringMatchers(name string, matchers map[string]StringMatcher, node *yaml.Node) {
+	keys := make([]string, 0, len(matchers))
+	for k := range matchers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v.validateStringMatcher(name+"."+k, matchers[k], field(node, k))
+	}
+}
+
+func (v *scenarioValidator) validateStringMatcher(name string, m StringMatcher, node *yaml.Node) {
+	switch {
+	case m.Absent && (m.Present || m.HasValueOperators()):
+		v.report(at(node), "%s: absent cannot be combined with other operators", name)
+	case !m.Absent && !m.Present && !m.HasValueOperators():
+		v.report(at(node), "%s has no operator (use present or absent to match on presence)", name)
+	}
+	if m.Regex != "" {
+		if _, err := CompileRegex(m.RegexPattern()); err != nil {
+			v.report(at(field(node, "regex"), node), "%s has an invalid regular expression: %v", name, err)
+		}
+	}
+}
+
+func (v *scenarioValidator) validateJSONMatchers(name string, m *MatchConfig, node *yaml.Node) {
+	jsonNode := field(node, "jsonBody")
+	for i := range m.JSONBody {
+		v.validateJSONPathMatcher(fmt.Sprintf("%s.jsonBody[%d]", name, i), &m.JSONBody[i], item(jsonNode, i))
+	}
+
+	if len(m.EqualToJSON) == 0 && (m.IgnoreExtraFields || m.IgnoreArrayOrder) {
+		v.report(at(field(node, "ignoreExtraFields"), field(node, "ignoreArrayOrder"), node),
+			"%[1]s.ignoreExtraFields and %[1]s.ignoreArrayOrder only apply to %[1]s.equalToJson", name)
+	}
+}
+
+// validateJSONPathMatcher checks a jsonBody entry, or a GraphQL variables entry
+func (v *scenarioValidator) validateJSONPathMatcher(itemName string, jm *JSONPathMatcher, mNode *yaml.Node) {
+	if jm.Path == "" {
+		v.report(at(mNode), "%s.path is required", itemName)
+	} else if _, err := jsonpath.Parse(jm.Path); err != nil {
+		v.report(at(field(mNode, "path"), mNode), "%s.path: %v", itemName, err)
+	}
+	switch {
+	case jm.Absent && (jm.Exists || jm.HasValueOperators()):
+		v.report(at(mNode), "%s: absent cannot be combined with other operators", itemName)
+	case !jm.Absent && !jm.Exists && !jm.HasValueOperators():
+		v.report(at(mNode), "%s has no operator (use exists or absent to match on presence)", itemName)
+	}
+	if jm.Regex != "" {
+		if _, err := CompileRegex(jm.Regex); err != nil {
+			v.report(at(field(mNode, "regex"), mNode), "%s has an invalid regular expression: %v", itemName, err)
+		}
+	}
+	for _, op := range []struct {
+		key   string
+		value Number
+	}{{"gt", jm.Gt}, {"gte", jm.Gte}, {"lt", jm.Lt}, {"lte", jm.Lte}} {
+		if op.value == "" {
+			continue
+		}
+		if _, err := op.value.Float(); err != nil {
+			v.report(at(field(mNode, op.key), mNode), "%s.%s %q is not a number", itemName, op.key, string(op.value))
+		}
+	}
+}
+
+func (v *scenarioValidator) validateGraphQLMatcher(name string, m *GraphQLMatcher, node *yaml.Node) {
+	if !m.OperationName.IsZero() {
+		v.validateStringMatcher(name+".operationName", m.OperationName, field(node, "operationName"))
+	}
+	if m.OperationType != "" && !slices.Contains(GraphQLOperationTypes, m.OperationType) {
+		v.report(at(field(node, "operationType"), node), "%s.operationType %q must be one of %s", name, m.OperationType, strings.Join(GraphQLOperationTypes, ", "))
+	}
+	varsNode := field(node, "variables")
+	for i := range m.Variables {
+		v.validateJSONPathMatcher(fmt.Sprintf("%s.variables[%d]", name, i), &m.Variables[i], item(varsNode, i))
+	}
+}
+
+func (v *scenarioValidator) validatePartMatchers(name string, parts []PartMatcher, node *yaml.Node) {
+	for i := range parts {
+		pm := &parts[i]
+		itemName := fmt.Sprintf("%s.multipart[%d]", name, i)
+		pNode := item(node, i)
+		if pm.Name == "" {
+			v.report(at(pNode), "%s.name is required", itemName)
+		}
+		if pm.Absent && pm.HasOperators() {
+			v.report(at(pNode), "%s: absent cannot be combined with other operators", itemName)
+		}
+		if !pm.Filename.IsZero() {
+			v.validateStringMatcher(itemName+".filename", pm.Filename, field(pNode, "filename"))
+		}
+		if !pm.ContentType.IsZero() {
+			v.validateStringMatcher(itemName+".contentType", pm.ContentType, field(pNode, "contentType"))
+		}
+		if pm.MinSize < 0 || pm.MaxSize < 0 {
+			v.report(at(field(pNode, "minSize"), field(pNode, "maxSize"), pNode), "%s sizes must not be negative", itemName)
+		} else if pm.MaxSize > 0 && pm.MinSize > pm.MaxSize {
+			v.report(at(field(pNode, "minSize"), pNode), "%s.minSize must not exceed maxSize", itemName)
+		}
+		if pm.Body.Regex != "" {
+			bodyNode := field(pNode, "body")
+			if _, err := CompileRegex(pm.Body.Regex); err != nil {
+				v.report(at(field(bodyNode, "regex"), bodyNode, pNode), "%s.body has an invalid regular expression: %v", itemName, err)
+			}
+		}
+	}
+}
+
+func (v *scenarioValidator) validateResponse(name string, r *Response, node *yaml.Node) {
+	if r.Status < 100 || r.Status > 599 {
+		v.report(at(field(node, "status"), node), "%s.status %d is not a valid HTTP status code (100-599)", name, r.Status)
+	}
+	if r.Delay < 0 {
+		v.report(at(field(node, "delay"), node), "%s.delay must not be negative", name)
+	}
+	if r.DelayRange != "" {
+		if _, _, err := ParseDelayRange(r.DelayRange); err != nil {
+			v.report(at(field(node, "delayRange"), node), "%s.delayRange %v", name, err)
+		}
+	}
+	if r.BodyFile != "" {
+		if len(r.Body) > 0 {
+			v.report(at(field(node, "bodyFile"), node), "%s sets both body and bodyFile", name)
+		} else if info, err := os.Stat(r.BodyFilePath()); err != nil {
+			v.report(at(field(node, "bodyFile"), node), "%s.bodyFile %v", name, err)
+		} else if info.IsDir() {
+			v.report(at(field(node, "bodyFile"), node), "%s.bodyFile %q is a directory", name, r.BodyFile)
+		}
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		v.report(at(field(node, "probability"), node), "%s.probability %g must be between 0 and 1", name, r.Probability)
+	}
+	if len(r.GraphQLErrors) > 0 {
+		v.validateGraphQLErrors(name, r, node)
+	}
+}
+
+func (v *scenarioValidator) validateGraphQLErrors(name string, r *Response, node *yaml.Node) {
+	if len(r.Body) > 0 && !json.Valid(r.Body) {
+		v.report(at(field(node, "body"), node), "%s.body must be JSON data when graphqlErrors is set", name)
+	}
+	errorsNode := field(node, "graphqlErrors")
+	for i, e := range r.GraphQLErrors {
+		itemName := fmt.Sprintf("%s.graphqlErrors[%d]", name, i)
+		eNode := item(errorsNode, i)
+		if e.Message == "" {
+			v.report(at(eNode), "%s.message is required", itemName)
+		}
+		if len(e.Path) > 0 {
+			var path []interface{}
+			valid := json.Unmarshal(e.Path, &path) == nil
+			for _, segment := range path {
+				if n, ok := segment.(float64); ok && n == float64(int(n)) {
+					continue
+				}
+				if _, ok := segment.(string); !ok {
+					valid = false
+				}
+			}
+			if !valid {
+				v.report(at(field(eNode, "path"), eNode), "%s.path must be a list of field names and list indices", itemName)
+			}
+		}
+		if len(e.Extensions) > 0 {
+			var extensions map[string]interface{}
+			if json.Unmarshal(e.Extensions, &extensions) != nil || extensions == nil {
+				v.report(at(field(eNode, "extensions"), eNode), "%s.extensions must be a mapping", itemName)
+			}
+		}
+	}
+}
+
+// ParseDelayRange parses a delay range such as "100ms-500ms"
+func ParseDelayRange(delayRange string) (time.Duration, time.Duration, error) {
+	parts := strings.Split(delayRange, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%q must have the form min-max, e.g. 100ms-500ms", delayRange)
+	}
+	minDelay, err := time.ParseDuration(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q has an invalid minimum: %v", delayRange, err)
+	}
+	maxDelay, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q has an invalid maximum: %v", delayRange, err)
+	}
+	if minDelay < 0 || maxDelay <= minDelay {
+		return 0, 0, fmt.Errorf("%q must have 0 <= min < max (use delay for a fixed delay)", delayRange)
+	}
+	return minDelay, maxDelay, nil
+}
+
+// field returns the value node for key in a mapping node, or nil.
+// Keys are compared case-insensitively so JSON bodies decoded by encoding/json line up.
+func field(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, key) {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// item returns the i-th element of a sequence node, or nil
+func item(node *yaml.Node, i int) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+		return nil
+	}
+	return node.Content[i]
+}
@@ -0,0 +1,354 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateScenarioFiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	writeFile(t, file, `- path: /lint
+  method: get
+  matches:
+    body: "/[unclosed/"
+  responses:
+    - status: 0
+      delayRange: 500ms-100ms
+      probability: 1.5
+- path: /empty
+  method: GET
+  responses: []
+`)
+
+	_, err := ValidateScenarioFiles(false, file)
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "Expected a ValidationError, got %v", err)
+
+	positions := make(map[string]Problem)
+	for _, p := range verr.Problems {
+		assert.Equal(t, file, p.File)
+		positions[p.Message] = p
+	}
+	require.Len(t, verr.Problems, 6)
+
+	expected := []struct {
+		message      string
+		line, column int
+	}{
+		{`get /lint: method "get" is not a valid HTTP method (methods are case-sensitive, e.g. GET)`, 2, 11},
+		{"get /lint: matches.body has an invalid regular expression: error parsing regexp: missing closing ]: `[unclosed`", 4, 11},
+		{"get /lint: responses[0].status 0 is not a valid HTTP status code (100-599)", 6, 15},
+		{`get /lint: responses[0].delayRange "500ms-100ms" must have 0 <= min < max (use delay for a fixed delay)`, 7, 19},
+		{"get /lint: responses[0].probability 1.5 must be between 0 and 1", 8, 20},
+		{"GET /empty: at least one response is required", 11, 14},
+	}
+	for _, e := range expected {
+		p, ok := positions[e.message]
+		if assert.True(t, ok, "Missing problem %q", e.message) {
+			assert.Equal(t, e.line, p.Line, "Line for %q", e.message)
+			assert.Equal(t, e.column, p.Column, "Column for %q", e.message)
+		}
+	}
+
+	_, err = ValidateScenarioFiles(false, filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err, "Missing files should be reported")
+}
+
+func TestParseDelayRange(t *testing.T) {
+	minDelay, maxDelay, err := ParseDelayRange("100ms - 500ms")
+	require.NoError(t, err)
+	assert.Equal(t, 100*time.Millisecond, minDelay)
+	assert.Equal(t, 500*time.Millisecond, maxDelay)
+
+	for _, invalid := range []string{"100ms", "100ms-", "fast-slow", "1s-1s", "2s-1s"} {
+		_, _, err := ParseDelayRange(invalid)
+		assert.Error(t, err, "Expected error for %q", invalid)
+	}
+}
+
+func TestValidateScenarioFiles_BodyFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "fixtures", "user.json"), `{"id": 1}`)
+	file := filepath.Join(dir, "scenarios.yaml")
+	writeFile(t, file, `
+- path: /api/user
+  method: GET
+  responses:
+    - status: 200
+      bodyFile: fixtures/user.json
+    - status: 200
+      bodyFile: fixtures/missing.json
+    - status: 200
+      body: inline
+      bodyFile: fixtures/user.json
+`)
+
+	_, err := ValidateScenarioFiles(false, file)
+	require.Error(t, err)
+	problems := err.(*ValidationError).Problems
+	require.Len(t, problems, 2)
+	assert.Contains(t, problems[0].String(), ":8:17: GET /api/user: responses[1].bodyFile stat "+filepath.Join(dir, "fixtures", "missing.json"))
+	assert.Contains(t, problems[1].String(), ":11:17: GET /api/user: responses[2] sets both body and bodyFile")
+
+	writeFile(t, file, "- path: /api/user\n  method: GET\n  responses:\n    - status: 200\n      bodyFile: fixtures/user.json\n")
+	loaded, err := ValidateScenarioFiles(false, file)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "fixtures", "user.json"), loaded[0].Responses[0].BodyFilePath(), "bodyFile is relative to the scenario file")
+}
+
+func TestValidateScenario_StringMatchers(t *testing.T) {
+	s := &Scenario{Path: "/a", Method: "GET", Responses: []Response{{Status: 200}}, Matches: MatchConfig{
+		Headers: map[string]StringMatcher{
+			"A": {Absent: true, Equals: "x"},
+			"B": {CaseInsensitive: true},
+			"C": {Regex: "("},
+			"D": {Present: true, Prefix: "x"},
+		},
+	}}
+	problems := ValidateScenario(s, "", nil)
+	require.Len(t, problems, 3)
+	assert.Contains(t, problems[0].Message, "matches.headers.A: absent cannot be combined")
+	assert.Contains(t, problems[1].Message, "matches.headers.B has no operator")
+	assert.Contains(t, problems[2].Message, "matches.headers.C has an invalid regular expression")
+}
+
+func TestValidateScenario_JSONMatchers(t *testing.T) {
+	s := &Scenario{Path: "/a", Method: "POST", Responses: []Response{{Status: 200}}, Matches: MatchConfig{
+		JSONBody: []JSONPathMatcher{
+			{Path: "$.items[*].sku", Equals: JSONValue(`"OUT-OF-STOCK"`)},
+			{Equals: JSONValue(`1`)},
+			{Path: "$.items[?(@.qty)]", Exists: true},
+			{Path: "$.a", Absent: true, Gt: "1"},
+			{Path: "$.a"},
+			{Path: "$.a", Regex: "("},
+			{Path: "$.a", Lte: "ten"},
+		},
+		IgnoreExtraFields: true,
+	}}
+	problems := ValidateScenario(s, "", nil)
+	require.Len(t, problems, 7)
+	assert.Contains(t, problems[0].Message, "matches.jsonBody[1].path is required")
+	assert.Contains(t, problems[1].Message, "matches.jsonBody[2].path: jsonpath")
+	assert.Contains(t, problems[2].Message, "matches.jsonBody[3]: absent cannot be combined")
+	assert.Contains(t, problems[3].Message, "matches.jsonBody[4] has no operator")
+	assert.Contains(t, problems[4].Message, "matches.jsonBody[5] has an invalid regular expression")
+	assert.Contains(t, problems[5].Message, `matches.jsonBody[6].lte "ten" is not a number`)
+	assert.Contains(t, problems[6].Message, "only apply to matches.equalToJson")
+}
+
+func TestValidateScenarioFiles_BodyRegex(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scenarios.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+- path: /a
+  method: POST
+  matches:
+    body: "regex:(unclosed"
+  responses:
+    - status: 200
+- path: /b
+  method: POST
+  matches:
+    body:
+      regex: "[z-a]"
+  responses:
+    - status: 200
+`), 0644))
+
+	_, err := ValidateScenarioFiles(false, path)
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr.Problems, 2)
+	assert.Equal(t, 5, verr.Problems[0].Line)
+	assert.Contains(t, verr.Problems[0].Message, "matches.body has an invalid regular expression")
+	assert.Equal(t, 12, verr.Problems[1].Line)
+}
+
+func TestValidateScenario_CallerMatchers(t *testing.T) {
+	s := &Scenario{Path: "/a", Method: "GET", Responses: []Response{{Status: 200}}, Matches: MatchConfig{
+		Cookies:     map[string]StringMatcher{"session": {CaseInsensitive: true}},
+		ContentType: StringMatcher{Regex: "("},
+		ClientIP:    []string{"10.0.0.0/8", "::1", "10.0.0.300", "10.0.0.0/40"},
+	}}
+	problems := ValidateScenario(s, "", nil)
+	require.Len(t, problems, 4)
+	assert.Contains(t, problems[0].Message, "matches.cookies.session has no operator")
+	assert.Contains(t, problems[1].Message, "matches.contentType has an invalid regular expression")
+	assert.Contains(t, problems[2].Message, `matches.clientIp[2] "10.0.0.300" is not an IP address`)
+	assert.Contains(t, problems[3].Message, `matches.clientIp[3]`)
+}
+
+func TestValidateScenario_Combinators(t *testing.T) {
+	s := &Scenario{Path: "/a", Method: "GET", Responses: []Response{{Status: 200}}, Matches: MatchConfig{
+		AnyOf: []MatchConfig{
+			{Headers: map[string]StringMatcher{"X-A": {Regex: "("}}},
+			{},
+		},
+		Not: MatchList{{AllOf: []MatchConfig{{ClientIP: []string{"nope"}}}}},
+	}}
+	problems := ValidateScenario(s, "", nil)
+	require.Len(t, problems, 3)
+	assert.Contains(t, problems[0].Message, "matches.anyOf[0].headers.X-A has an invalid regular expression")
+	assert.Contains(t, problems[1].Message, "matches.anyOf[1] has no matchers")
+	assert.Contains(t, problems[2].Message, `matches.not[0].allOf[0].clientIp[0] "nope"`)
+}
+
+func TestValidateScenario_PathTemplate(t *testing.T) {
+	s := &Scenario{Path: "/files/**/meta", Method: "GET", Responses: []Response{{Status: 200}}}
+	problems := ValidateScenario(s, "", nil)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "path: ** must be the last segment")
+
+	s.Path = "/users/{id:[0-9}"
+	problems = ValidateScenario(s, "", nil)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, `variable "id" in "/users/{id:[0-9}" has an invalid pattern`)
+}
+
+func TestValidateScenario_FormMatchers(t *testing.T) {
+	s := &Scenario{Path: "/a", Method: "POST", Responses: []Response{{Status: 200}}, Matches: MatchConfig{
+		Form: map[string]StringMatcher{"plan": {}},
+		Multipart: []PartMatcher{
+			{Filename: StringMatcher{Suffix: ".png"}},
+			{Name: "avatar", Absent: true, MaxSize: 10},
+			{Name: "avatar", MinSize: 10, MaxSize: 5, Body: BodyMatcher{Regex: "["}},
+		},
+	}}
+	problems := ValidateScenario(s, "", nil)
+	require.Len(t, problems, 5)
+	assert.Contains(t, problems[0].Message, "matches.form.plan has no operator")
+	assert.Contains(t, problems[1].Message, "matches.multipart[0].name is required")
+	assert.Contains(t, problems[2].Message, "matches.multipart[1]: absent cannot be combined")
+	assert.Contains(t, problems[3].Message, "matches.multipart[2].minSize must not exceed maxSize")
+	assert.Contains(t, problems[4].Message, "matches.multipart[2].body has an invalid regular expression")
+}
+
+func TestValidateScenario_GraphQL(t *testing.T) {
+	s := &Scenario{Path: "/graphql", Method: "POST",
+		Matches: MatchConfig{GraphQL: GraphQLMatcher{
+			OperationType: "Query",
+			Variables:     []JSONPathMatcher{{Path: "$.id"}},
+		}},
+		Responses: []Response{{Status: 200, Body: JSONBody("not json"), GraphQLErrors: []GraphQLError{
+			{Path: JSONValue(`["hero", 0.5]`), Extensions: JSONValue(`"code"`)},
+		}}}}
+	problems := ValidateScenario(s, "", nil)
+	require.Len(t, problems, 6)
+	assert.Contains(t, problems[0].Message, `matches.graphql.operationType "Query" must be one of query, mutation, subscription`)
+	assert.Contains(t, problems[1].Message, "matches.graphql.variables[0] has no operator")
+	assert.Contains(t, problems[2].Message, "responses[0].body must be JSON data when graphqlErrors is set")
+	assert.Contains(t, problems[3].Message, "responses[0].graphqlErrors[0].message is required")
+	assert.Contains(t, problems[4].Message, "responses[0].graphqlErrors[0].path must be a list")
+	assert.Contains(t, problems[5].Message, "responses[0].graphqlErrors[0].extensions must be a mapping")
+}
+
+func TestValidateScenario_Strategy(t *testing.T) {
+	s := &Scenario{Path: "/a", Method: "GET", Strategy: "round-robin", Responses: []Response{{Status: 200, Weight: 2}}}
+	problems := ValidateScenario(s, "", nil)
+	require.Len(t, problems, 2)
+	assert.Contains(t, problems[0].Message, `strategy "round-robin" must be one of sequential, random, weighted, shuffle, sticky-last`)
+	assert.Contains(t, problems[1].Message, "responses[0].weight only applies to the weighted strategy")
+
+	s.Strategy = StrategyWeighted
+	s.Responses = append(s.Responses, Response{Status: 500, Weight: -1})
+	problems = ValidateScenario(s, "", nil)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "responses[1].weight must not be negative")
+}
+
+func TestValidateScenario_SequenceKey(t *testing.T) {
+	s := &Scenario{Path: "/users/{id}", Method: "GET", Responses: []Response{{Status: 200}},
+		SequenceKey: SequenceKey{Header: "X-Shard", Cookie: "shard"}}
+	problems := ValidateScenario(s, "", nil)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "sequenceKey sets header, cookie; set exactly one")
+
+	s.SequenceKey = SequenceKey{PathVar: "name"}
+	problems = ValidateScenario(s, "", nil)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, `sequenceKey.pathVar "name" is not a variable of path "/users/{id}"`)
+
+	s.SequenceKey = SequenceKey{PathVar: "id"}
+	assert.Empty(t, ValidateScenario(s, "", nil))
+}
+
+func TestValidateScenario_StateMachine(t *testing.T) {
+	s := &Scenario{Path: "/a", Method: "GET", StateMachine: "orders", Responses: []Response{{Status: 200}}}
+	problems := ValidateScenario(s, "", nil)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "stateMachine has no effect without whenState or setState")
+
+	s.Responses[0].SetState = "shipped"
+	assert.Empty(t, ValidateScenario(s, "", nil))
+}
+
+func TestValidateResource(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource Resource
+		want     string
+	}{
+		{"valid", Resource{Path: "/users", Seed: []JSONValue{JSONValue(`{"id": 1}`), JSONValue(`{"name": "no id"}`)}}, ""},
+		{"missing path", Resource{}, "resource: path is required"},
+		{"trailing slash", Resource{Path: "/users/"}, "must not end with /"},
+		{"root", Resource{Path: "/"}, "path must name a collection"},
+		{"path variable", Resource{Path: "/teams/{team}/users"}, "must not contain path variables"},
+		{"seed not an object", Resource{Path: "/users", Seed: []JSONValue{JSONValue(`[1]`)}}, "seed[0] must be a mapping"},
+		{"invalid seed id", Resource{Path: "/users", Seed: []JSONValue{JSONValue(`{"id": true}`)}}, "seed[0].id must be a string or an integer"},
+		{"duplicate seed id", Resource{Path: "/users", IDField: "key", Seed: []JSONValue{JSONValue(`{"key": 1}`), JSONValue(`{"key": "1"}`)}}, `seed[1].key "1" is already used`},
+		{"invalid fault", Resource{Path: "/users", Faults: []Response{{Status: 503, Probability: 2}}}, "resource /users: faults[0].probability 2 must be between 0 and 1"},
+		{"invalid delay range", Resource{Path: "/users", DelayRange: "fast"}, "delayRange"},
+		{"circuit breaker without timeout", Resource{Path: "/users", CircuitBreaker: CircuitBreakerConfig{FailureThreshold: 1}}, "circuitBreaker.timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := ValidateResource(&tt.resource, "", nil)
+			if tt.want == "" {
+				assert.Empty(t, problems)
+				return
+			}
+			require.Len(t, problems, 1, "%v", problems)
+			assert.Contains(t, problems[0].Message, tt.want)
+		})
+	}
+}
+
+func TestValidateScenario_OnExhausted(t *testing.T) {
+	var s Scenario
+	require.NoError(t, yaml.Unmarshal([]byte("path: /a\nmethod: GET\nonExhausted: 404\nresponses:\n  - status: 503\n    times: 3\n"), &s))
+	assert.Equal(t, ExhaustedNotFound, s.OnExhausted, "404 may be written unquoted")
+	assert.Equal(t, 3, s.Responses[0].Times)
+	assert.Empty(t, ValidateScenario(&s, "", nil))
+
+	tests := []struct {
+		name     string
+		scenario Scenario
+		want     string
+	}{
+		{"unknown behavior", Scenario{OnExhausted: "stop"}, `onExhausted "stop" must be one of loop, stickLast, fallthrough, 404`},
+		{"unordered strategy", Scenario{Strategy: StrategyRandom, OnExhausted: ExhaustedLoop}, "onExhausted only applies to the sequential strategy"},
+		{"negative times", Scenario{Responses: []Response{{Status: 200, Times: -1}}}, "responses[0].times must not be negative"},
+		{"times with shuffle", Scenario{Strategy: StrategyShuffle, Responses: []Response{{Status: 200, Times: 2}}}, "responses[0].times only applies to the sequential and sticky-last strategies"},
+		{"times too large", Scenario{Responses: []Response{{Status: 200, Times: MaxSequenceSteps + 1}}}, "responses[0].times must be at most 2147483647"},
+		{"too many steps", Scenario{Responses: []Response{{Status: 200, Times: MaxSequenceSteps}, {Status: 500}}}, "responses must add up to at most 2147483647 steps counting times, got 2147483648"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.scenario
+			s.Path, s.Method = "/a", "GET"
+			if s.Responses == nil {
+				s.Responses = []Response{{Status: 200}}
+			}
+			problems := ValidateScenario(&s, "", nil)
+			require.Len(t, problems, 1, "%v", problems)
+			assert.Contains(t, problems[0].Message, tt.want)
+		})
+	}
+}
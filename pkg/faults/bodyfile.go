@@ -0,0 +1,57 @@
+package faults
+
+import (
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// bodyFileEntry is a cached response body file
+type bodyFileEntry struct {
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+var (
+	bodyFileCache   = make(map[string]*bodyFileEntry)
+	bodyFileCacheMu sync.Mutex
+)
+
+// readBodyFile returns the contents of a response body file. Files are cached in memory
+// and read again when their modification time or size changes.
+func readBodyFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyFileCacheMu.Lock()
+	entry, ok := bodyFileCache[path]
+	bodyFileCacheMu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.data, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyFileCacheMu.Lock()
+	bodyFileCache[path] = &bodyFileEntry{modTime: info.ModTime(), size: info.Size(), data: data}
+	bodyFileCacheMu.Unlock()
+	return data, nil
+}
+
+// bodyFileContentType infers the Content-Type of a body file from its extension,
+// falling back to sniffing the content
+func bodyFileContentType(path string, data []byte) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(data)
+}
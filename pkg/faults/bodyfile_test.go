@@ -0,0 +1,66 @@
+package faults
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyFile(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "user.json")
+	xmlFile := filepath.Join(dir, "user.xml")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"id": "{{.Request.Query.id}}"}`), 0o644))
+	require.NoError(t, os.WriteFile(xmlFile, []byte(`<user/>`), 0o644))
+
+	config.AddScenario(&config.Scenario{
+		Path:   "/test-bodyfile",
+		Method: "GET",
+		Responses: []config.Response{
+			{Status: 200, BodyFile: "user.json", BodyPath: jsonFile},
+			{Status: 200, BodyFile: xmlFile, Headers: map[string]string{"content-type": "text/plain"}},
+		},
+	})
+	r := mux.NewRouter()
+	r.HandleFunc("/test-bodyfile", HandleScenario).Methods("GET")
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/test-bodyfile?id=42", nil))
+		return w
+	}
+
+	// Templated, Content-Type from the extension
+	w := get()
+	assert.Equal(t, `{"id": "42"}`, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	// Explicit header wins
+	w = get()
+	assert.Equal(t, "<user/>", w.Body.String())
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+
+	// Changed files are read again
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"changed": true}`), 0o644))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(jsonFile, future, future))
+	assert.Equal(t, `{"changed": true}`, get().Body.String())
+
+	// A missing file is a server error, not a crash
+	require.NoError(t, os.Remove(xmlFile))
+	assert.Equal(t, http.StatusInternalServerError, get().Code)
+}
+
+func TestBodyFileContentType(t *testing.T) {
+	assert.Equal(t, "application/json", bodyFileContentType("a.json", nil))
+	assert.Contains(t, bodyFileContentType("a.xml", nil), "xml")
+	assert.Equal(t, "application/octet-stream", bodyFileContentType("a.unknownext", []byte{0x00, 0x01, 0xff}))
+	assert.Contains(t, bodyFileContentType("noext", []byte("plain words")), "text/plain")
+}
@@ -6,15 +6,16 @@ import (
 	"github.com/arun0009/go-resilience-mock/pkg/config"
 )
 
-// checkCircuitBreaker returns true if request is allowed, false if blocked (Open state)
-func checkCircuitBreaker(s *config.Scenario) bool {
-	s.CBState.Mutex.Lock()
-	defer s.CBState.Mutex.Unlock()
+// checkCircuitBreaker returns true if request is allowed, false if blocked (Open state).
+// cb is the scenario's state, or that of the request's sequence key.
+func checkCircuitBreaker(s *config.Scenario, cb *config.CircuitBreakerState) bool {
+	cb.Mutex.Lock()
+	defer cb.Mutex.Unlock()
 
-	if s.CBState.State == "open" {
-		if time.Since(s.CBState.LastTransition) > s.CircuitBreaker.Timeout {
-			s.CBState.State = "half-open"
-			s.CBState.LastTransition = time.Now()
+	if cb.State == "open" {
+		if time.Since(cb.LastTransition) > s.CircuitBreaker.Timeout {
+			cb.State = "half-open"
+			cb.LastTransition = time.Now()
 			// Allow this request to pass through to test recovery
 			return true
 		}
@@ -24,44 +25,44 @@ func checkCircuitBreaker(s *config.Scenario) bool {
 }
 
 // updateCircuitBreaker updates the state based on request outcome
-func updateCircuitBreaker(s *config.Scenario, success bool) {
-	s.CBState.Mutex.Lock()
-	defer s.CBState.Mutex.Unlock()
+func updateCircuitBreaker(s *config.Scenario, cb *config.CircuitBreakerState, success bool) {
+	cb.Mutex.Lock()
+	defer cb.Mutex.Unlock()
 
-	if s.CBState.State == "open" {
+	if cb.State == "open" {
 		// Should not happen if checkCircuitBreaker blocked it, but if it was half-open:
 		// Wait, if it was open and we are here, it means it transitioned to half-open in check?
 		// No, checkCircuitBreaker changes state.
 		return
 	}
 
-	if s.CBState.State == "half-open" {
+	if cb.State == "half-open" {
 		if success {
-			s.CBState.Successes++
-			if s.CBState.Successes >= s.CircuitBreaker.SuccessThreshold {
-				s.CBState.State = "closed"
-				s.CBState.Failures = 0
-				s.CBState.Successes = 0
-				s.CBState.LastTransition = time.Now()
+			cb.Successes++
+			if cb.Successes >= s.CircuitBreaker.SuccessThreshold {
+				cb.State = "closed"
+				cb.Failures = 0
+				cb.Successes = 0
+				cb.LastTransition = time.Now()
 			}
 		} else {
-			s.CBState.State = "open"
-			s.CBState.LastTransition = time.Now()
+			cb.State = "open"
+			cb.LastTransition = time.Now()
 		}
 		return
 	}
 
 	// Closed State
 	if !success {
-		s.CBState.Failures++
-		s.CBState.LastFailure = time.Now()
-		if s.CBState.Failures >= s.CircuitBreaker.FailureThreshold {
-			s.CBState.State = "open"
-			s.CBState.LastTransition = time.Now()
+		cb.Failures++
+		cb.LastFailure = time.Now()
+		if cb.Failures >= s.CircuitBreaker.FailureThreshold {
+			cb.State = "open"
+			cb.LastTransition = time.Now()
 		}
 	} else {
 		// Reset failures on success in closed state?
 		// Usually yes, or use a sliding window. Simple counter reset for now.
-		s.CBState.Failures = 0
+		cb.Failures = 0
 	}
 }
@@ -21,9 +21,6 @@ func TestCircuitBreaker(t *testing.T) {
 			SuccessThreshold: 1,
 			Timeout:          100 * time.Millisecond,
 		},
-		CBState: &config.CircuitBreakerState{
-			State: "closed",
-		},
 		Responses: []config.Response{
 			{Status: 500, Body: config.JSONBody(`"fail"`)},
 		},
@@ -40,13 +37,13 @@ func TestCircuitBreaker(t *testing.T) {
 	w1 := httptest.NewRecorder()
 	r.ServeHTTP(w1, req)
 	assert.Equal(t, 500, w1.Code, "Expected 500")
-	assert.Equal(t, 1, scenario.CBState.Failures, "Expected 1 failure")
+	assert.Equal(t, 1, scenario.Runtime.CBState.Failures, "Expected 1 failure")
 
 	// 2. Second Failure (Trip)
 	w2 := httptest.NewRecorder()
 	r.ServeHTTP(w2, req)
 	assert.Equal(t, 500, w2.Code, "Expected 500")
-	assert.Equal(t, "open", scenario.CBState.State, "Expected state 'open'")
+	assert.Equal(t, "open", scenario.Runtime.CBState.State, "Expected state 'open'")
 
 	// 3. Third Request (Blocked)
 	w3 := httptest.NewRecorder()
@@ -63,5 +60,5 @@ func TestCircuitBreaker(t *testing.T) {
 	w4 := httptest.NewRecorder()
 	r.ServeHTTP(w4, req)
 	assert.Equal(t, 200, w4.Code, "Expected 200")
-	assert.Equal(t, "closed", scenario.CBState.State, "Expected state 'closed'")
+	assert.Equal(t, "closed", scenario.Runtime.CBState.State, "Expected state 'closed'")
 }
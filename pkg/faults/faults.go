@@ -2,6 +2,7 @@ package faults
 
 import (
 	"bytes"
+	"cmp"
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/arun0009/go-resilience-mock/pkg/config"
 	"github.com/arun0009/go-resilience-mock/pkg/observability"
@@ -27,91 +29,126 @@ import (
 
 type contextKey string
 
-const MatchedTemplateKey contextKey = "matchedTemplate"
-
+// HandleScenario serves a request from the first enabled scenario that matches it, in the
+// evaluation order of Candidates. Requests for a scenario path that no scenario matches
+// fall back to echo with the nearest scenarios in X-Mock-Near-Misses; requests for unknown
+// paths get a 404 listing them. Both are recorded with their near misses (see
+// UnmatchedRequests). Scenarios whose sequence is exhausted with onExhausted: fallthrough
+// pass the request on to the next matching scenario, or to echo.
 func HandleScenario(w http.ResponseWriter, r *http.Request) {
-
-	scenariosMap := config.GetScenarios()
-
-	// We must look up the scenario using the registered template path, not the raw request path.
-	route := mux.CurrentRoute(r)
-	pathTemplate, err := route.GetPathTemplate()
-	if err != nil || pathTemplate == "/" {
-		// Fallback for exact matches if no template logic was used or if matched via catch-all
-		pathTemplate = r.URL.Path
-	}
-
-	key := pathTemplate + "_" + r.Method
-
-	// Check if we have a forced template match from the catch-all handler
-	if tmpl := r.Context().Value(MatchedTemplateKey); tmpl != nil {
-		key = tmpl.(string) + "_" + r.Method
-	}
-
-	v, ok := scenariosMap.Load(key)
-	if !ok {
-		// Safe fallback if lookup fails
-		log.Printf("Scenario mismatch: %s not found", key)
-		http.Error(w, "Scenario not found for path/method", http.StatusNotFound)
+	candidates := Candidates(r.Method, r.URL.Path)
+	if len(candidates) == 0 {
+		u := recordUnmatched(r)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":      fmt.Sprintf("no scenario matches %s %s", r.Method, r.URL.Path),
+			"nearMisses": PublicNearMisses(u.NearMisses),
+		})
 		return
 	}
 
-	scenariosList := v.([]*config.Scenario)
-	var scenario *config.Scenario
-
-	// Find the first matching scenario
-	for _, s := range scenariosList {
-		if matchesRequest(s, r) {
-			scenario = s
-			break
+	fellThrough := false
+	for _, c := range candidates {
+		if c.Scenario.Disabled {
+			continue
+		}
+		req := r
+		if len(c.Vars) > 0 {
+			// Expose path variables to matchers and templates
+			req = mux.SetURLVars(r, c.Vars)
+		}
+		if matchesRequest(c.Scenario, req) {
+			if serveScenario(w, req, c.Scenario, c.Template) {
+				return
+			}
+			fellThrough = true
 		}
 	}
 
-	if scenario == nil {
-		// No matching scenario found, fallback to Echo
-		HandleEcho(w, r)
-		return
+	if !fellThrough {
+		// No matching scenario found
+		u := recordUnmatched(r)
+		w.Header().Set("X-Mock-Unmatched", "true")
+		if misses, err := json.Marshal(PublicNearMisses(u.NearMisses)); err == nil {
+			w.Header().Set("X-Mock-Near-Misses", string(misses))
+		}
 	}
+	HandleEcho(w, r)
+}
 
+// serveScenario writes the next response of a scenario that matched r. It reports false,
+// without writing anything, if the scenario's sequence is exhausted and falls through.
+func serveScenario(w http.ResponseWriter, r *http.Request, scenario *config.Scenario, pathTemplate string) bool {
 	// --- Circuit Breaker Check ---
+	seq := sequenceFor(scenario, r)
 	if scenario.CircuitBreaker.FailureThreshold > 0 {
-		if !checkCircuitBreaker(scenario) {
+		if !checkCircuitBreaker(scenario, seq.cb) {
+			atomic.AddUint64(&scenario.Runtime.Hits, 1)
 			http.Error(w, "Service Unavailable (Circuit Breaker Open)", http.StatusServiceUnavailable)
-			return
+			return true
 		}
 	}
 
-	// Atomically increment and wrap the index
-	idx := int(atomic.LoadInt32(&scenario.Index))
-	response := scenario.Responses[idx]
-
-	nextIdx := (idx + 1) % len(scenario.Responses)
-	atomic.StoreInt32(&scenario.Index, int32(nextIdx))
+	var response config.Response
+	operation := false // Response is the result of a resource operation, not a fault
+	if scenario.Resource != "" {
+		c := config.LookupCollection(scenario.Resource)
+		if c == nil {
+			http.Error(w, "Resource not declared", http.StatusInternalServerError)
+			return true
+		}
+		response, operation = resourceResponse(c, r)
+	} else {
+		if len(scenario.Responses) == 0 {
+			// Rejected by validation, but scenarios can also be added directly via config.AddScenario
+			http.Error(w, "Scenario has no responses", http.StatusInternalServerError)
+			return true
+		}
 
-	// --- 0. Probability Check ---
-	// If Probability is set (e.g. 0.25), we only trigger the fault 25% of the time.
-	// If probability check fails, skip this response and try the next one.
-	if response.Probability > 0.0 && response.Probability < 1.0 {
-		if mrand.Float64() > response.Probability {
-			// Probability check failed; skip to next response
-			// Recursively call HandleScenario to try the next response in sequence
-			HandleScenario(w, r)
-			return
+		idx, ok := selectResponse(scenario, seq)
+		if !ok {
+			if scenario.OnExhausted == config.ExhaustedFallthrough {
+				return false
+			}
+			atomic.AddUint64(&scenario.Runtime.Hits, 1)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("scenario %s has served every response", scenario.ID),
+			})
+			return true
+		}
+		response = scenario.Responses[idx]
+
+		// --- 0. Probability Check ---
+		// If Probability is set (e.g. 0.25), we only trigger the fault 25% of the time.
+		// If probability check fails, skip this response and try the next one.
+		if response.Probability > 0.0 && response.Probability < 1.0 {
+			if mrand.Float64() > response.Probability {
+				// Probability check failed; skip to next response
+				// Recursively call HandleScenario to try the next response in sequence
+				HandleScenario(w, r)
+				return true
+			}
 		}
 	}
 
+	// Counted after the probability check, which retries with the next response
+	atomic.AddUint64(&scenario.Runtime.Hits, 1)
+
+	// Move the state machine before templating, so templates see the new state
+	if state := cmp.Or(response.SetState, scenario.SetState); state != "" {
+		config.SetMachineState(scenario.StateMachineName(), state)
+	}
+
 	// --- 1. Fault Injection: Delay ---
 	var actualDelay time.Duration
 	if response.DelayRange != "" {
 		// Parse delay range (e.g., "100ms-500ms")
-		parts := strings.Split(response.DelayRange, "-")
-		if len(parts) == 2 {
-			minDelay, err1 := time.ParseDuration(strings.TrimSpace(parts[0]))
-			maxDelay, err2 := time.ParseDuration(strings.TrimSpace(parts[1]))
-			if err1 == nil && err2 == nil && maxDelay > minDelay {
-				delta := maxDelay - minDelay
-				actualDelay = minDelay + time.Duration(mrand.Int63n(int64(delta)))
-			}
+		if minDelay, maxDelay, err := config.ParseDelayRange(response.DelayRange); err == nil {
+			delta := maxDelay - minDelay
+			actualDelay = minDelay + time.Duration(mrand.Int63n(int64(delta)))
 		}
 	} else if response.Delay > 0 {
 		actualDelay = response.Delay
@@ -127,13 +164,13 @@ func HandleScenario(w http.ResponseWriter, r *http.Request) {
 	if response.Status >= 500 {
 		isFailure = true
 		observability.FaultsInjected.WithLabelValues("http_error", pathTemplate).Inc()
-	} else if response.Status >= 400 {
+	} else if response.Status >= 400 && !operation {
 		observability.FaultsInjected.WithLabelValues("http_error", pathTemplate).Inc()
 	}
 
 	// Update Circuit Breaker State
 	if scenario.CircuitBreaker.FailureThreshold > 0 {
-		updateCircuitBreaker(scenario, !isFailure)
+		updateCircuitBreaker(scenario, seq.cb, !isFailure)
 	}
 
 	// --- 2. Headers and Status ---
@@ -144,20 +181,47 @@ func HandleScenario(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// --- 3. Dynamic Response Templating ---
+	body := []byte(response.Body)
+	if path := response.BodyFilePath(); path != "" {
+		data, err := readBodyFile(path)
+		if err != nil {
+			log.Printf("Error reading body file for %s: %v", r.URL.Path, err)
+			http.Error(w, "Internal Server Error (Body File)", http.StatusInternalServerError)
+			return true
+		}
+		body = data
+		// An explicit Content-Type in headers wins
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", bodyFileContentType(path, data))
+		}
+	}
+
+	if len(response.GraphQLErrors) > 0 {
+		var err error
+		if body, err = graphqlResponseBody(body, response.GraphQLErrors); err != nil {
+			log.Printf("Error building GraphQL response for %s: %v", r.URL.Path, err)
+			http.Error(w, "Internal Server Error (GraphQL)", http.StatusInternalServerError)
+			return true
+		}
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+	}
+
 	var finalBody []byte
-	bodyStr := string(response.Body)
-	if strings.Contains(bodyStr, "{{") {
+	bodyStr := string(body)
+	if !operation && utf8.Valid(body) && strings.Contains(bodyStr, "{{") { // Items are data, not templates
 		var err error
 		var result string
 		result, err = executeTemplate(bodyStr, r)
 		if err != nil {
 			log.Printf("Error executing template for %s: %v", r.URL.Path, err)
 			http.Error(w, "Internal Server Error (Template)", http.StatusInternalServerError)
-			return
+			return true
 		}
 		finalBody = []byte(result)
 	} else {
-		finalBody = response.Body
+		finalBody = body
 	}
 
 	// --- 4. Content Encoding (Gzip) ---
@@ -168,7 +232,7 @@ func HandleScenario(w http.ResponseWriter, r *http.Request) {
 		if _, err := gz.Write(bodyBytes); err != nil {
 			log.Printf("Error gzipping response: %v", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
+			return true
 		}
 		_ = gz.Close()
 
@@ -181,9 +245,14 @@ func HandleScenario(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(response.Status)
 
 	// --- 5. Write Body ---
+	// Statuses such as 204 do not allow a body, not even an empty one
+	if len(bodyBytes) == 0 {
+		return true
+	}
 	if _, err := w.Write(bodyBytes); err != nil {
 		log.Printf("Error writing response body: %v", err)
 	}
+	return true
 }
 
 // --- NEW Chaos/Stress Handlers ---
@@ -0,0 +1,123 @@
+package faults
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+)
+
+// formBody holds the fields of a form-encoded or multipart/form-data request body
+type formBody struct {
+	values url.Values // Form fields, excluding multipart file parts
+	parts  []formPart // Every multipart part, in request order
+}
+
+// formPart is a single part of a multipart/form-data body
+type formPart struct {
+	name        string
+	filename    string
+	contentType string // Media type without parameters
+	data        []byte
+}
+
+// parseForm decodes a request body according to its Content-Type. Bodies that are not
+// forms, and parts after a malformed one, yield no fields.
+func parseForm(contentType string, data []byte) *formBody {
+	form := &formBody{values: url.Values{}}
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return form
+	}
+
+	switch mt {
+	case "application/x-www-form-urlencoded":
+		// ParseQuery keeps the fields it could decode on error
+		form.values, _ = url.ParseQuery(string(data))
+	case "multipart/form-data":
+		reader := multipart.NewReader(bytes.NewReader(data), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			content, err := io.ReadAll(part)
+			if err != nil {
+				break
+			}
+			p := formPart{name: part.FormName(), filename: part.FileName(), data: content}
+			if ct := part.Header.Get("Content-Type"); ct != "" {
+				p.contentType = mediaType(ct)
+			}
+			form.parts = append(form.parts, p)
+			if p.filename == "" {
+				form.values.Add(p.name, string(content))
+			}
+		}
+	}
+	return form
+}
+
+// partsNamed returns the multipart parts with the given field name
+func (f *formBody) partsNamed(name string) []formPart {
+	var parts []formPart
+	for _, p := range f.parts {
+		if p.name == name {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// matchForm checks the form field and multipart matchers of m
+func matchForm(m *config.MatchConfig, form *formBody) bool {
+	for k, fm := range m.Form {
+		if !matchString(fm, form.values[k]) {
+			return false
+		}
+	}
+	for i := range m.Multipart {
+		if !matchParts(&m.Multipart[i], form.partsNamed(m.Multipart[i].Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchParts succeeds if any of the parts satisfies every operator of m
+func matchParts(m *config.PartMatcher, parts []formPart) bool {
+	if m.Absent {
+		return len(parts) == 0
+	}
+	for _, p := range parts {
+		if matchPart(m, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPart(m *config.PartMatcher, p formPart) bool {
+	if !m.Filename.IsZero() && !matchString(m.Filename, optionalValue(p.filename)) {
+		return false
+	}
+	if !m.ContentType.IsZero() && !matchString(m.ContentType, optionalValue(p.contentType)) {
+		return false
+	}
+	size := int64(len(p.data))
+	if size < m.MinSize || (m.MaxSize > 0 && size > m.MaxSize) {
+		return false
+	}
+	return m.Body.IsZero() || matchBody(&m.Body, string(p.data))
+}
+
+// optionalValue returns v as a single value, or none when it is empty
+func optionalValue(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return []string{v}
+}
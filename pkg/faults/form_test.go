@@ -0,0 +1,80 @@
+package faults
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multipartBody has a title field and a report.pdf upload, separated by the boundary XYZ
+const multipartBody = "--XYZ\r\n" +
+	"Content-Disposition: form-data; name=\"title\"\r\n\r\n" +
+	"Q3\r\n" +
+	"--XYZ\r\n" +
+	"Content-Disposition: form-data; name=\"doc\"; filename=\"report.pdf\"\r\n" +
+	"Content-Type: application/pdf\r\n\r\n" +
+	"%PDF-1.7\r\n" +
+	"--XYZ--\r\n"
+
+func TestParseForm(t *testing.T) {
+	form := parseForm("application/x-www-form-urlencoded; charset=utf-8", []byte("plan=pro&tag=a&tag=b"))
+	assert.Equal(t, "pro", form.values.Get("plan"))
+	assert.Equal(t, []string{"a", "b"}, form.values["tag"])
+	assert.Empty(t, form.parts)
+
+	form = parseForm("multipart/form-data; boundary=XYZ", []byte(multipartBody))
+	assert.Equal(t, map[string][]string{"title": {"Q3"}}, map[string][]string(form.values), "File parts are not form fields")
+	require.Len(t, form.parts, 2)
+	assert.Equal(t, formPart{name: "doc", filename: "report.pdf", contentType: "application/pdf", data: []byte("%PDF-1.7")}, form.parts[1])
+
+	assert.Empty(t, parseForm("application/json", []byte(`{"plan":"pro"}`)).values)
+	assert.Empty(t, parseForm("multipart/form-data; boundary=XYZ", []byte("garbage")).parts)
+}
+
+func TestMatchingRules_Form(t *testing.T) {
+	tests := []struct {
+		name        string
+		matches     config.MatchConfig
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"Form field", config.MatchConfig{Form: map[string]config.StringMatcher{"plan": {Equals: "pro"}}}, "application/x-www-form-urlencoded", "plan=pro", true},
+		{"Form field mismatch", config.MatchConfig{Form: map[string]config.StringMatcher{"plan": {Equals: "pro"}}}, "application/x-www-form-urlencoded", "plan=free", false},
+		{"Form field absent from JSON body", config.MatchConfig{Form: map[string]config.StringMatcher{"plan": {Absent: true}}}, "application/json", `{"plan":"pro"}`, true},
+		{"Multipart field", config.MatchConfig{Form: map[string]config.StringMatcher{"title": {Prefix: "Q"}}}, "multipart/form-data; boundary=XYZ", multipartBody, true},
+		{"File part by name", config.MatchConfig{Multipart: []config.PartMatcher{{Name: "doc"}}}, "multipart/form-data; boundary=XYZ", multipartBody, true},
+		{"File part by filename and type", config.MatchConfig{Multipart: []config.PartMatcher{{
+			Name: "doc", Filename: config.StringMatcher{Suffix: ".PDF", CaseInsensitive: true}, ContentType: config.StringMatcher{Equals: "application/pdf"},
+		}}}, "multipart/form-data; boundary=XYZ", multipartBody, true},
+		{"File part too large", config.MatchConfig{Multipart: []config.PartMatcher{{Name: "doc", MaxSize: 4}}}, "multipart/form-data; boundary=XYZ", multipartBody, false},
+		{"File part big enough", config.MatchConfig{Multipart: []config.PartMatcher{{Name: "doc", MinSize: 8}}}, "multipart/form-data; boundary=XYZ", multipartBody, true},
+		{"File part content", config.MatchConfig{Multipart: []config.PartMatcher{{Name: "doc", Body: config.BodyMatcher{Contains: "PDF"}}}}, "multipart/form-data; boundary=XYZ", multipartBody, true},
+		{"Field part is not a file", config.MatchConfig{Multipart: []config.PartMatcher{{Name: "title", Filename: config.StringMatcher{Absent: true}}}}, "multipart/form-data; boundary=XYZ", multipartBody, true},
+		{"Part absent", config.MatchConfig{Multipart: []config.PartMatcher{{Name: "avatar", Absent: true}}}, "multipart/form-data; boundary=XYZ", multipartBody, true},
+		{"Part missing", config.MatchConfig{Multipart: []config.PartMatcher{{Name: "avatar"}}}, "multipart/form-data; boundary=XYZ", multipartBody, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/upload", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			assert.Equal(t, tt.want, matchesRequest(&config.Scenario{Matches: tt.matches}, req))
+		})
+	}
+}
+
+func TestExplainConfig_Multipart(t *testing.T) {
+	m := &config.MatchConfig{Multipart: []config.PartMatcher{{Name: "doc", Filename: config.StringMatcher{Suffix: ".png"}, MaxSize: 4}}}
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader(multipartBody))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=XYZ")
+	assert.Equal(t, []Mismatch{{
+		Matcher:  "matches.multipart[0]",
+		Expected: `part "doc", filename suffix ".png", maxSize 4`,
+		Actual:   `"report.pdf" application/pdf 8 bytes`,
+	}}, explainConfig("matches", m, req, &requestBody{r: req}))
+}
@@ -0,0 +1,193 @@
+package faults
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+)
+
+// graphqlRequest is the operation a GraphQL request asks for
+type graphqlRequest struct {
+	operationName string // From the request, or the name of the document's only operation
+	operationType string // query, mutation or subscription; empty if the operation was not found
+	variables     interface{}
+}
+
+// graphqlOperation is an operation definition in a GraphQL document
+type graphqlOperation struct {
+	typ  string
+	name string
+}
+
+// parseGraphQL extracts the GraphQL operation from a request: a JSON POST body with query,
+// operationName and variables, an application/graphql body, or the same fields as GET
+// query parameters
+func parseGraphQL(r *http.Request, body *requestBody) (*graphqlRequest, bool) {
+	var document, operationName string
+	var variables interface{}
+	switch {
+	case r.Method == http.MethodGet:
+		query := r.URL.Query()
+		if !query.Has("query") {
+			return nil, false
+		}
+		document, operationName = query.Get("query"), query.Get("operationName")
+		if v := query.Get("variables"); v != "" && json.Unmarshal([]byte(v), &variables) != nil {
+			return nil, false
+		}
+	case mediaType(r.Header.Get("Content-Type")) == "application/graphql":
+		data, ok := body.bytes()
+		if !ok {
+			return nil, false
+		}
+		document = string(data)
+	default:
+		doc, ok := body.json()
+		obj, isObject := doc.(map[string]interface{})
+		if !ok || !isObject {
+			return nil, false
+		}
+		if document, ok = obj["query"].(string); !ok {
+			return nil, false
+		}
+		operationName, _ = obj["operationName"].(string)
+		variables = obj["variables"]
+	}
+
+	req := &graphqlRequest{operationName: operationName, variables: variables}
+	operations := graphqlOperations(document)
+	switch {
+	case operationName != "":
+		if i := slices.IndexFunc(operations, func(op graphqlOperation) bool { return op.name == operationName }); i >= 0 {
+			req.operationType = operations[i].typ
+		}
+	case len(operations) == 1:
+		req.operationName, req.operationType = operations[0].name, operations[0].typ
+	}
+	return req, true
+}
+
+// graphqlOperations returns the operations defined in a GraphQL document, in order. It only
+// reads the definition headers: fragments, selections, arguments, comments and strings are
+// skipped.
+func graphqlOperations(document string) []graphqlOperation {
+	var operations []graphqlOperation
+	var header []string // Names at the top level since the previous definition
+	depth, parens := 0, 0
+	for i := 0; i < len(document); i++ {
+		c := document[i]
+		switch {
+		case c == '#':
+			for i < len(document) && document[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			i = graphqlStringEnd(document, i)
+		case c == '(':
+			parens++
+		case c == ')':
+			parens--
+		case c == '{':
+			if depth == 0 && parens == 0 {
+				if op, ok := graphqlOperationHeader(header); ok {
+					operations = append(operations, op)
+				}
+				header = nil
+			}
+			depth++
+		case c == '}':
+			depth--
+		case depth == 0 && parens == 0 && isGraphQLNameStart(c):
+			end := i
+			for end < len(document) && (isGraphQLNameStart(document[end]) || document[end] >= '0' && document[end] <= '9') {
+				end++
+			}
+			if i == 0 || document[i-1] != '@' { // Directive names are not part of the header
+				header = append(header, document[i:end])
+			}
+			i = end - 1
+		}
+	}
+	return operations
+}
+
+// graphqlOperationHeader returns the operation a definition header declares. An empty header
+// is the query shorthand; fragments are not operations.
+func graphqlOperationHeader(header []string) (graphqlOperation, bool) {
+	if len(header) == 0 {
+		return graphqlOperation{typ: "query"}, true
+	}
+	if !slices.Contains(config.GraphQLOperationTypes, header[0]) {
+		return graphqlOperation{}, false
+	}
+	op := graphqlOperation{typ: header[0]}
+	if len(header) > 1 {
+		op.name = header[1]
+	}
+	return op, true
+}
+
+// graphqlStringEnd returns the index of the quote closing the string or block string at
+// document[start]
+func graphqlStringEnd(document string, start int) int {
+	if strings.HasPrefix(document[start:], `"""`) {
+		for i := start + 3; i < len(document); i++ {
+			if document[i] == '\\' && strings.HasPrefix(document[i+1:], `"""`) {
+				i += 3
+				continue
+			}
+			if strings.HasPrefix(document[i:], `"""`) {
+				return i + 2
+			}
+		}
+		return len(document)
+	}
+	for i := start + 1; i < len(document); i++ {
+		switch document[i] {
+		case '\\':
+			i++
+		case '"', '\n':
+			return i
+		}
+	}
+	return len(document)
+}
+
+func isGraphQLNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// matchGraphQL checks the GraphQL matchers of m
+func matchGraphQL(m *config.GraphQLMatcher, req *graphqlRequest) bool {
+	if !m.OperationName.IsZero() && !matchString(m.OperationName, optionalValue(req.operationName)) {
+		return false
+	}
+	if m.OperationType != "" && req.operationType != m.OperationType {
+		return false
+	}
+	for i := range m.Variables {
+		if !matchJSONPath(&m.Variables[i], req.variables) {
+			return false
+		}
+	}
+	return true
+}
+
+// graphqlResponseBody wraps a response body in a GraphQL response with errors. The body is
+// the data, or null when empty.
+func graphqlResponseBody(data []byte, errors []config.GraphQLError) ([]byte, error) {
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("GraphQL data is not JSON")
+	}
+	return json.Marshal(struct {
+		Data   json.RawMessage       `json:"data"`
+		Errors []config.GraphQLError `json:"errors"`
+	}{data, errors})
+}
@@ -0,0 +1,113 @@
+package faults
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQLOperations(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []graphqlOperation
+	}{
+		{"Shorthand", "{ hero { name } }", []graphqlOperation{{typ: "query"}}},
+		{"Named query", "query GetHero($ep: Episode = JEDI) { hero(episode: $ep) { name } }", []graphqlOperation{{"query", "GetHero"}}},
+		{"Anonymous mutation", "mutation { like(id: 1) }", []graphqlOperation{{typ: "mutation"}}},
+		{"Directive", "query @cached(ttl: 60) { hero { name } }", []graphqlOperation{{typ: "query"}}},
+		{"Fragments are skipped", "fragment F on Hero { name }\nsubscription OnReview { review { ...F } }", []graphqlOperation{{"subscription", "OnReview"}}},
+		{"Several operations", "query A { a }\nmutation B { b }", []graphqlOperation{{"query", "A"}, {"mutation", "B"}}},
+		{"Comments and strings", "# query Commented { x }\nquery Q { search(text: \"mutation M {\") { id } note(text: \"\"\"} \\\"\"\" {\"\"\") }", []graphqlOperation{{"query", "Q"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, graphqlOperations(tt.document))
+		})
+	}
+}
+
+func TestMatchingRules_GraphQL(t *testing.T) {
+	getHero := `{"query": "query GetHero($episode: Episode) { hero(episode: $episode) { name } }", "variables": {"episode": "JEDI", "limit": 3}}`
+	batch := `{"query": "query A { a } mutation B { b }", "operationName": "B"}`
+
+	tests := []struct {
+		name    string
+		matches config.GraphQLMatcher
+		body    string
+		want    bool
+	}{
+		{"Operation name from document", config.GraphQLMatcher{OperationName: config.StringMatcher{Equals: "GetHero"}}, getHero, true},
+		{"Operation name mismatch", config.GraphQLMatcher{OperationName: config.StringMatcher{Equals: "GetVillain"}}, getHero, false},
+		{"Operation type", config.GraphQLMatcher{OperationType: "query"}, getHero, true},
+		{"Selected operation type", config.GraphQLMatcher{OperationName: config.StringMatcher{Equals: "B"}, OperationType: "mutation"}, batch, true},
+		{"Selected operation type mismatch", config.GraphQLMatcher{OperationType: "query"}, batch, false},
+		{"Variables", config.GraphQLMatcher{Variables: []config.JSONPathMatcher{{Path: "$.episode", Equals: config.JSONValue(`"JEDI"`)}, {Path: "limit", Lte: "5"}}}, getHero, true},
+		{"Variables mismatch", config.GraphQLMatcher{Variables: []config.JSONPathMatcher{{Path: "$.episode", Equals: config.JSONValue(`"EMPIRE"`)}}}, getHero, false},
+		{"Variables absent", config.GraphQLMatcher{Variables: []config.JSONPathMatcher{{Path: "$.episode", Absent: true}}}, batch, true},
+		{"Not a GraphQL request", config.GraphQLMatcher{OperationType: "query"}, `{"operation": "GetHero"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/graphql", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			assert.Equal(t, tt.want, matchesRequest(&config.Scenario{Matches: config.MatchConfig{GraphQL: tt.matches}}, req))
+		})
+	}
+}
+
+func TestMatchingRules_GraphQLTransports(t *testing.T) {
+	m := config.MatchConfig{GraphQL: config.GraphQLMatcher{
+		OperationName: config.StringMatcher{Equals: "GetHero"},
+		Variables:     []config.JSONPathMatcher{{Path: "$.episode", Equals: config.JSONValue(`"JEDI"`)}},
+	}}
+
+	query := url.Values{"query": {"query GetHero { hero { name } }"}, "variables": {`{"episode": "JEDI"}`}}
+	assert.True(t, matchesRequest(&config.Scenario{Matches: m}, httptest.NewRequest("GET", "/graphql?"+query.Encode(), nil)))
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader("query GetHero { hero { name } }"))
+	req.Header.Set("Content-Type", "application/graphql")
+	assert.False(t, matchesRequest(&config.Scenario{Matches: m}, req), "application/graphql bodies have no variables")
+	m.GraphQL.Variables = nil
+	assert.True(t, matchesRequest(&config.Scenario{Matches: m}, req))
+}
+
+func TestGraphQLErrorsResponse(t *testing.T) {
+	config.AddScenario(&config.Scenario{Path: "/graphql-errors-test", Method: "POST",
+		Matches: config.MatchConfig{GraphQL: config.GraphQLMatcher{OperationName: config.StringMatcher{Equals: "GetHero"}}},
+		Responses: []config.Response{{
+			Status: 200,
+			Body:   config.JSONBody(`{"hero": {"name": "R2-D2", "friends": null}}`),
+			GraphQLErrors: []config.GraphQLError{{
+				Message:    "friends service unavailable",
+				Path:       config.JSONValue(`["hero","friends"]`),
+				Extensions: config.JSONValue(`{"code":"UNAVAILABLE"}`),
+			}},
+		}, {
+			Status:        200,
+			GraphQLErrors: []config.GraphQLError{{Message: "rate limited"}},
+		}}})
+
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		HandleScenario(w, httptest.NewRequest("POST", "/graphql-errors-test", strings.NewReader(`{"query": "query GetHero { hero { name } }"}`)))
+		return w
+	}
+
+	w := send()
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"data": {"hero": {"name": "R2-D2", "friends": null}},
+		"errors": [{"message": "friends service unavailable", "path": ["hero", "friends"], "extensions": {"code": "UNAVAILABLE"}}]
+	}`, w.Body.String())
+
+	assert.JSONEq(t, `{"data": null, "errors": [{"message": "rate limited"}]}`, send().Body.String(), "No body means no data")
+}
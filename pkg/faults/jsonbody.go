@@ -0,0 +1,209 @@
+package faults
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/arun0009/go-resilience-mock/pkg/jsonpath"
+)
+
+// matchJSONBody checks the JSONPath matchers and equalToJson of m against a decoded JSON request body
+func matchJSONBody(m *config.MatchConfig, doc interface{}) bool {
+	for i := range m.JSONBody {
+		if !matchJSONPath(&m.JSONBody[i], doc) {
+			return false
+		}
+	}
+
+	if len(m.EqualToJSON) > 0 {
+		var expected interface{}
+		if err := json.Unmarshal(m.EqualToJSON, &expected); err != nil {
+			return false
+		}
+		if !jsonEqual(expected, doc, m.IgnoreExtraFields, m.IgnoreArrayOrder) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchJSONPath checks the values m.Path selects from doc.
+// Value operators succeed if any selected value satisfies all of them.
+func matchJSONPath(m *config.JSONPathMatcher, doc interface{}) bool {
+	path, err := compileJSONPath(m.Path)
+	if err != nil {
+		return false
+	}
+	values := path.Select(doc)
+
+	if m.Absent {
+		return len(values) == 0
+	}
+	if m.Exists && len(values) == 0 {
+		return false
+	}
+	if !m.HasValueOperators() {
+		return true
+	}
+	for _, v := range values {
+		if matchJSONValue(m, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchJSONValue checks a single selected value against every value operator of m
+func matchJSONValue(m *config.JSONPathMatcher, v interface{}) bool {
+	if len(m.Equals) > 0 {
+		var expected interface{}
+		if err := json.Unmarshal(m.Equals, &expected); err != nil || !jsonEqual(expected, v, false, false) {
+			return false
+		}
+	}
+	if len(m.Contains) > 0 {
+		var expected interface{}
+		if err := json.Unmarshal(m.Contains, &expected); err != nil || !jsonContains(v, expected) {
+			return false
+		}
+	}
+	if m.Regex != "" {
+		re, err := config.CompileRegex(m.Regex)
+		if err != nil || !re.MatchString(jsonText(v)) {
+			return false
+		}
+	}
+	for _, cmp := range []struct {
+		operand config.Number
+		holds   func(a, b float64) bool
+	}{
+		{m.Gt, func(a, b float64) bool { return a > b }},
+		{m.Gte, func(a, b float64) bool { return a >= b }},
+		{m.Lt, func(a, b float64) bool { return a < b }},
+		{m.Lte, func(a, b float64) bool { return a <= b }},
+	} {
+		if cmp.operand == "" {
+			continue
+		}
+		n, ok := v.(float64)
+		operand, err := cmp.operand.Float()
+		if !ok || err != nil || !cmp.holds(n, operand) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonContains reports whether v is an array with an element equal to expected,
+// or a string containing the string expected
+func jsonContains(v, expected interface{}) bool {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, e := range v {
+			if jsonEqual(expected, e, false, false) {
+				return true
+			}
+		}
+	case string:
+		if s, ok := expected.(string); ok {
+			return strings.Contains(v, s)
+		}
+	}
+	return false
+}
+
+// jsonText returns strings as they are and other values as JSON
+func jsonText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// jsonEqual compares decoded JSON values. With ignoreExtraFields, actual objects may have
+// members that expected does not; with ignoreArrayOrder, arrays match in any order.
+func jsonEqual(expected, actual interface{}, ignoreExtraFields, ignoreArrayOrder bool) bool {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok || (!ignoreExtraFields && len(a) != len(e)) {
+			return false
+		}
+		for k, ev := range e {
+			av, ok := a[k]
+			if !ok || !jsonEqual(ev, av, ignoreExtraFields, ignoreArrayOrder) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		if !ignoreArrayOrder {
+			for i := range e {
+				if !jsonEqual(e[i], a[i], ignoreExtraFields, ignoreArrayOrder) {
+					return false
+				}
+			}
+			return true
+		}
+		// An element may match several others (e.g. with ignoreExtraFields), so pair them
+		// as a bipartite matching rather than taking the first match
+		matches := make([][]int, len(e))
+		for i, ev := range e {
+			for j, av := range a {
+				if jsonEqual(ev, av, ignoreExtraFields, ignoreArrayOrder) {
+					matches[i] = append(matches[i], j)
+				}
+			}
+			if len(matches[i]) == 0 {
+				return false
+			}
+		}
+		return perfectMatching(matches, len(a))
+	default:
+		return reflect.DeepEqual(expected, actual)
+	}
+}
+
+// perfectMatching reports whether every expected element can be paired with its own actual
+// element, where matches[i] lists the actual elements expected element i matches
+func perfectMatching(matches [][]int, n int) bool {
+	pairedWith := make([]int, n) // Expected element paired with each actual element, or -1
+	for j := range pairedWith {
+		pairedWith[j] = -1
+	}
+	// augment looks for a pairing of i, re-pairing earlier elements along the way
+	var augment func(i int, visited []bool) bool
+	augment = func(i int, visited []bool) bool {
+		for _, j := range matches[i] {
+			if visited[j] {
+				continue
+			}
+			visited[j] = true
+			if pairedWith[j] < 0 || augment(pairedWith[j], visited) {
+				pairedWith[j] = i
+				return true
+			}
+		}
+		return false
+	}
+	for i := range matches {
+		if !augment(i, make([]bool, n)) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonPathCache holds compiled JSONPath expressions, so each is parsed once while in use
+var jsonPathCache = config.NewCompileCache(jsonpath.Parse)
+
+func compileJSONPath(expr string) (*jsonpath.Path, error) {
+	return jsonPathCache.Get(expr)
+}
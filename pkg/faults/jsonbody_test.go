@@ -0,0 +1,114 @@
+package faults
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const orderBody = `{
+	"id": "o-1",
+	"total": 120.5,
+	"customer": {"id": 7, "tier": "gold"},
+	"items": [
+		{"sku": "A-1", "qty": 1},
+		{"sku": "OUT-OF-STOCK", "qty": 2}
+	],
+	"tags": ["vip", "priority"]
+}`
+
+func TestMatchJSONBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		matcher  config.JSONPathMatcher
+		expected bool
+	}{
+		{"equals any element", config.JSONPathMatcher{Path: "$.items[*].sku", Equals: config.JSONValue(`"OUT-OF-STOCK"`)}, true},
+		{"equals mismatch", config.JSONPathMatcher{Path: "$.items[*].sku", Equals: config.JSONValue(`"B-2"`)}, false},
+		{"equals is typed", config.JSONPathMatcher{Path: "$.customer.id", Equals: config.JSONValue(`"7"`)}, false},
+		{"equals number", config.JSONPathMatcher{Path: "$.customer.id", Equals: config.JSONValue(`7.0`)}, true},
+		{"equals object ignores key order", config.JSONPathMatcher{Path: "$.customer", Equals: config.JSONValue(`{"tier": "gold", "id": 7}`)}, true},
+		{"regex", config.JSONPathMatcher{Path: "$.id", Regex: `^o-\d+$`}, true},
+		{"regex on number", config.JSONPathMatcher{Path: "$.total", Regex: `^120\.`}, true},
+		{"exists", config.JSONPathMatcher{Path: "$.customer.tier", Exists: true}, true},
+		{"exists missing", config.JSONPathMatcher{Path: "$.customer.email", Exists: true}, false},
+		{"absent", config.JSONPathMatcher{Path: "$.customer.email", Absent: true}, true},
+		{"gt", config.JSONPathMatcher{Path: "$.total", Gt: "100"}, true},
+		{"gt and lt", config.JSONPathMatcher{Path: "$.total", Gt: "100", Lt: "120"}, false},
+		{"gte zero", config.JSONPathMatcher{Path: "$.items[*].qty", Gte: "2"}, true},
+		{"lte", config.JSONPathMatcher{Path: "$.items[*].qty", Lte: "0"}, false},
+		{"comparison on string", config.JSONPathMatcher{Path: "$.id", Gt: "0"}, false},
+		{"array contains", config.JSONPathMatcher{Path: "$.tags", Contains: config.JSONValue(`"vip"`)}, true},
+		{"array contains object", config.JSONPathMatcher{Path: "$.items", Contains: config.JSONValue(`{"sku": "A-1", "qty": 1}`)}, true},
+		{"array contains mismatch", config.JSONPathMatcher{Path: "$.tags", Contains: config.JSONValue(`"basic"`)}, false},
+		{"string contains", config.JSONPathMatcher{Path: "$.customer.tier", Contains: config.JSONValue(`"ol"`)}, true},
+		{"operators must hold for the same value", config.JSONPathMatcher{Path: "$.items[*].qty", Gt: "1", Lt: "2"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &config.MatchConfig{JSONBody: []config.JSONPathMatcher{tt.matcher}}
+			assert.Equal(t, tt.expected, matchJSONBody(m, decodeJSON(t, orderBody)))
+		})
+	}
+}
+
+func TestMatchJSONBody_EqualToJSON(t *testing.T) {
+	body := decodeJSON(t, `{"b": [1, 2, 3], "a": {"x": 1, "y": 2}}`)
+	tests := []struct {
+		name              string
+		expected          string
+		ignoreExtraFields bool
+		ignoreArrayOrder  bool
+		matches           bool
+	}{
+		{"whitespace and key order", `{"a":{"y":2,"x":1},"b":[1,2,3]}`, false, false, true},
+		{"extra fields", `{"a": {"x": 1}, "b": [1, 2, 3]}`, false, false, false},
+		{"ignore extra fields", `{"a": {"x": 1}}`, true, false, true},
+		{"array order", `{"a": {"x": 1, "y": 2}, "b": [3, 2, 1]}`, false, false, false},
+		{"ignore array order", `{"a": {"x": 1, "y": 2}, "b": [3, 2, 1]}`, false, true, true},
+		{"arrays keep their length", `{"b": [1, 2]}`, true, true, false},
+		{"different value", `{"a": {"x": 2}}`, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &config.MatchConfig{EqualToJSON: config.JSONValue(tt.expected), IgnoreExtraFields: tt.ignoreExtraFields, IgnoreArrayOrder: tt.ignoreArrayOrder}
+			assert.Equal(t, tt.matches, matchJSONBody(m, body))
+		})
+	}
+
+	// {"a":1} matches both actual elements, so taking the first match would leave {"a":1,"b":2} unpaired
+	m := &config.MatchConfig{EqualToJSON: config.JSONValue(`[{"a": 1}, {"a": 1, "b": 2}]`), IgnoreExtraFields: true, IgnoreArrayOrder: true}
+	assert.True(t, matchJSONBody(m, decodeJSON(t, `[{"a": 1, "b": 2}, {"a": 1}]`)))
+	assert.False(t, matchJSONBody(m, decodeJSON(t, `[{"a": 1}, {"a": 1}]`)))
+}
+
+func TestMatchingRules_JSONBody(t *testing.T) {
+	s := &config.Scenario{Matches: config.MatchConfig{
+		JSONBody: []config.JSONPathMatcher{{Path: "$.items[*].sku", Equals: config.JSONValue(`"OUT-OF-STOCK"`)}},
+	}}
+
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(orderBody))
+	assert.True(t, matchesRequest(s, req))
+
+	req = httptest.NewRequest("POST", "/orders", strings.NewReader(`{"items": [{"sku": "A-1"}]}`))
+	assert.False(t, matchesRequest(s, req))
+
+	req = httptest.NewRequest("POST", "/orders", nil)
+	assert.False(t, matchesRequest(s, req))
+
+	s.Matches = config.MatchConfig{EqualToJSON: config.JSONValue(`{}`), IgnoreExtraFields: true}
+	req = httptest.NewRequest("POST", "/orders", strings.NewReader("not json"))
+	assert.False(t, matchesRequest(s, req), "Invalid JSON never matches")
+}
+
+func decodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(s), &doc))
+	return doc
+}
@@ -2,63 +2,324 @@ package faults
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
+	"mime"
+	"net"
 	"net/http"
-	"regexp"
+	"net/netip"
+	"slices"
 	"strings"
 
 	"github.com/arun0009/go-resilience-mock/pkg/config"
 )
 
-// matchesRequest checks if the request matches the scenario's rules
+// matchesRequest checks if the request matches the scenario's rules, and its state machine
+// is in the state the scenario requires
 func matchesRequest(s *config.Scenario, r *http.Request) bool {
+	if s.WhenState != "" && config.MachineState(s.StateMachineName()) != s.WhenState {
+		return false
+	}
+	return matchConfig(&s.Matches, r, &requestBody{r: r})
+}
+
+// matchConfig checks a match block. Cheap checks run first and evaluation stops at the
+// first failing matcher, so the body is only read when every other matcher holds.
+func matchConfig(m *config.MatchConfig, r *http.Request, body *requestBody) bool {
 	// 1. Headers
-	for k, v := range s.Matches.Headers {
-		if r.Header.Get(k) != v {
+	for k, hm := range m.Headers {
+		if !matchString(hm, r.Header.Values(k)) {
 			return false
 		}
 	}
 
 	// 2. Query Params
-	query := r.URL.Query()
-	for k, v := range s.Matches.Query {
-		if query.Get(k) != v {
+	if len(m.Query) > 0 {
+		query := r.URL.Query()
+		for k, qm := range m.Query {
+			if !matchString(qm, query[k]) {
+				return false
+			}
+		}
+	}
+
+	// 3. Callers
+	if !matchCaller(m, r) {
+		return false
+	}
+
+	// 4. Body
+	if !m.Body.IsZero() {
+		data, ok := body.bytes()
+		if !ok || !matchBody(&m.Body, string(data)) {
 			return false
 		}
 	}
 
-	// 3. Body (Regex) - Only if body matching is configured
-	if len(s.Matches.Body) > 0 {
-		if r.Body == nil {
+	// 5. JSON body
+	if len(m.JSONBody) > 0 || len(m.EqualToJSON) > 0 {
+		doc, ok := body.json()
+		if !ok || !matchJSONBody(m, doc) {
 			return false
 		}
-		// Read body (and restore it)
-		bodyBytes, err := io.ReadAll(r.Body)
-		if err != nil {
+	}
+
+	// 6. GraphQL operation
+	if !m.GraphQL.IsZero() {
+		req, ok := body.graphql()
+		if !ok || !matchGraphQL(&m.GraphQL, req) {
 			return false
 		}
-		r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	}
 
-		// Convert the expected body to string for comparison
-		expectedBody := string(s.Matches.Body)
+	// 7. Form fields and multipart parts
+	if len(m.Form) > 0 || len(m.Multipart) > 0 {
+		if !matchForm(m, body.form()) {
+			return false
+		}
+	}
 
-		// Remove surrounding quotes if present (from JSON)
-		expectedBody = strings.Trim(expectedBody, `"`)
+	// 8. Combinators
+	for i := range m.AllOf {
+		if !matchConfig(&m.AllOf[i], r, body) {
+			return false
+		}
+	}
+	if len(m.AnyOf) > 0 && !slices.ContainsFunc(m.AnyOf, func(alt config.MatchConfig) bool {
+		return matchConfig(&alt, r, body)
+	}) {
+		return false
+	}
+	for i := range m.Not {
+		if matchConfig(&m.Not[i], r, body) {
+			return false
+		}
+	}
+	return true
+}
 
-		// Check if the expected body is a regex pattern (starts and ends with /)
-		if len(expectedBody) > 2 && expectedBody[0] == '/' && expectedBody[len(expectedBody)-1] == '/' {
-			pattern := expectedBody[1 : len(expectedBody)-1]
-			matched, _ := regexp.MatchString(pattern, string(bodyBytes))
-			if !matched {
-				return false
-			}
-		} else {
-			// For exact match, check if the expected body is contained in the request body
-			if !strings.Contains(string(bodyBytes), expectedBody) {
-				return false
-			}
+// requestBody reads the request body at most once, however many matchers need it
+type requestBody struct {
+	r    *http.Request
+	read bool
+	data []byte
+	ok   bool
+
+	parsed bool
+	doc    interface{}
+	valid  bool
+
+	fields *formBody
+
+	gqlParsed bool
+	gql       *graphqlRequest
+	gqlValid  bool
+}
+
+// bytes returns the body, restoring it on the request for later readers
+func (b *requestBody) bytes() ([]byte, bool) {
+	if !b.read {
+		b.read = true
+		if b.r.Body == nil {
+			return nil, false
+		}
+		data, err := io.ReadAll(b.r.Body)
+		b.r.Body = io.NopCloser(bytes.NewBuffer(data))
+		b.data, b.ok = data, err == nil
+	}
+	return b.data, b.ok
+}
+
+// json returns the body decoded as JSON, or false if it is not valid JSON
+func (b *requestBody) json() (interface{}, bool) {
+	if !b.parsed {
+		b.parsed = true
+		if data, ok := b.bytes(); ok {
+			b.valid = json.Unmarshal(data, &b.doc) == nil
+		}
+	}
+	return b.doc, b.valid
+}
+
+// graphql returns the GraphQL operation of the request, or false if it is not a GraphQL request
+func (b *requestBody) graphql() (*graphqlRequest, bool) {
+	if !b.gqlParsed {
+		b.gqlParsed = true
+		b.gql, b.gqlValid = parseGraphQL(b.r, b)
+	}
+	return b.gql, b.gqlValid
+}
+
+// form returns the body decoded as a form, empty if it is not one
+func (b *requestBody) form() *formBody {
+	if b.fields == nil {
+		data, _ := b.bytes()
+		b.fields = parseForm(b.r.Header.Get("Content-Type"), data)
+	}
+	return b.fields
+}
+
+// matchCaller checks the matchers that identify who sent the request
+func matchCaller(m *config.MatchConfig, r *http.Request) bool {
+	for name, cm := range m.Cookies {
+		if !matchString(cm, cookieValues(r, name)) {
+			return false
+		}
+	}
+
+	if !m.Host.IsZero() && !matchString(m.Host, []string{requestHost(r)}) {
+		return false
+	}
+
+	if !m.ContentType.IsZero() && !matchString(m.ContentType, contentTypeValues(r)) {
+		return false
+	}
+
+	if len(m.ClientIP) > 0 {
+		ip, ok := clientIP(r)
+		if !ok || !slices.ContainsFunc(m.ClientIP, func(entry string) bool {
+			prefix, err := config.ParseClientIP(entry)
+			return err == nil && prefix.Contains(ip)
+		}) {
+			return false
+		}
+	}
+
+	if !m.ClientCertSubject.IsZero() && !matchString(m.ClientCertSubject, clientCertSubjects(r)) {
+		return false
+	}
+	return true
+}
+
+func cookieValues(r *http.Request, name string) []string {
+	var values []string
+	for _, c := range r.CookiesNamed(name) {
+		values = append(values, c.Value)
+	}
+	return values
+}
+
+// contentTypeValues returns the media type of the request, if it has a Content-Type
+func contentTypeValues(r *http.Request) []string {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		return []string{mediaType(ct)}
+	}
+	return nil
+}
+
+// clientCertSubjects returns the subject of the TLS client certificate, if one was presented
+func clientCertSubjects(r *http.Request) []string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return []string{r.TLS.PeerCertificates[0].Subject.String()}
+	}
+	return nil
+}
+
+// requestHost returns the Host header without the port
+func requestHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.Host); err == nil {
+		return host
+	}
+	return r.Host
+}
+
+// mediaType returns a Content-Type without its parameters, lower-cased
+func mediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// clientIP returns the address of the original client: the first X-Forwarded-For entry
+// when the request came through a proxy, otherwise the remote address
+func clientIP(r *http.Request) (netip.Addr, bool) {
+	candidate := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(candidate); err == nil {
+		candidate = host
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		first, _, _ := strings.Cut(xff, ",")
+		candidate = strings.TrimSpace(first)
+	}
+	addr, err := netip.ParseAddr(candidate)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// matchBody checks the raw request body against every operator of m
+func matchBody(m *config.BodyMatcher, body string) bool {
+	if m.Equals != "" && body != m.Equals {
+		return false
+	}
+	if m.Contains != "" && !strings.Contains(body, m.Contains) {
+		return false
+	}
+	if m.NotContains != "" && strings.Contains(body, m.NotContains) {
+		return false
+	}
+	if m.Regex != "" {
+		re, err := config.CompileRegex(m.Regex)
+		if err != nil || !re.MatchString(body) {
+			return false
 		}
 	}
+	return true
+}
 
+// matchString checks the values of a header or query parameter against a matcher.
+// Value operators succeed if any value satisfies all of them.
+func matchString(m config.StringMatcher, values []string) bool {
+	if m.Absent {
+		return len(values) == 0
+	}
+	if m.Present && len(values) == 0 {
+		return false
+	}
+	if !m.HasValueOperators() {
+		return true
+	}
+	for _, v := range values {
+		if matchValue(&m, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue checks a single value against every value operator of m
+func matchValue(m *config.StringMatcher, v string) bool {
+	fold := func(s string) string {
+		if m.CaseInsensitive {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	value := fold(v)
+
+	if m.Equals != "" && value != fold(m.Equals) {
+		return false
+	}
+	if m.Contains != "" && !strings.Contains(value, fold(m.Contains)) {
+		return false
+	}
+	if m.Prefix != "" && !strings.HasPrefix(value, fold(m.Prefix)) {
+		return false
+	}
+	if m.Suffix != "" && !strings.HasSuffix(value, fold(m.Suffix)) {
+		return false
+	}
+	if len(m.In) > 0 && !slices.ContainsFunc(m.In, func(candidate string) bool { return fold(candidate) == value }) {
+		return false
+	}
+	if m.Regex != "" {
+		re, err := config.CompileRegex(m.RegexPattern())
+		if err != nil || !re.MatchString(v) {
+			return false
+		}
+	}
 	return true
 }
@@ -1,6 +1,10 @@
 package faults
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -18,7 +22,7 @@ func TestMatchingRules(t *testing.T) {
 			Path:   "/match",
 			Method: "POST",
 			Matches: config.MatchConfig{
-				Headers: map[string]string{"X-Test": "A"},
+				Headers: map[string]config.StringMatcher{"X-Test": {Equals: "A"}},
 			},
 			Responses: []config.Response{{Status: 201, Body: config.JSONBody(`"Matched Header"`)}},
 		},
@@ -26,7 +30,7 @@ func TestMatchingRules(t *testing.T) {
 			Path:   "/match",
 			Method: "POST",
 			Matches: config.MatchConfig{
-				Query: map[string]string{"type": "B"},
+				Query: map[string]config.StringMatcher{"type": {Equals: "B"}},
 			},
 			Responses: []config.Response{{Status: 202, Body: config.JSONBody(`"Matched Query"`)}},
 		},
@@ -34,7 +38,7 @@ func TestMatchingRules(t *testing.T) {
 			Path:   "/match",
 			Method: "POST",
 			Matches: config.MatchConfig{
-				Body: config.JSONBody(`"/^START.*END$/"`), // Use forward slashes to indicate regex pattern
+				Body: config.BodyMatcher{Regex: "^START.*END$"},
 			},
 			Responses: []config.Response{{Status: 203, Body: config.JSONBody(`"Matched Body Regex"`)}},
 		},
@@ -79,3 +83,184 @@ func TestMatchingRules(t *testing.T) {
 	r.ServeHTTP(w4, req4)
 	assert.Equal(t, 200, w4.Code, "Expected 200 (Fallback)")
 }
+
+func TestMatchString(t *testing.T) {
+	tests := []struct {
+		name     string
+		matcher  config.StringMatcher
+		values   []string
+		expected bool
+	}{
+		{"equals", config.StringMatcher{Equals: "acme"}, []string{"acme"}, true},
+		{"equals is case-sensitive", config.StringMatcher{Equals: "acme"}, []string{"ACME"}, false},
+		{"equals case-insensitive", config.StringMatcher{Equals: "acme", CaseInsensitive: true}, []string{"ACME"}, true},
+		{"equals missing", config.StringMatcher{Equals: "acme"}, nil, false},
+		{"contains", config.StringMatcher{Contains: "json"}, []string{"application/json; charset=utf-8"}, true},
+		{"prefix", config.StringMatcher{Prefix: "Bearer expired-"}, []string{"Bearer expired-123"}, true},
+		{"prefix mismatch", config.StringMatcher{Prefix: "Bearer expired-"}, []string{"Bearer valid-123"}, false},
+		{"prefix case-insensitive", config.StringMatcher{Prefix: "bearer ", CaseInsensitive: true}, []string{"Bearer x"}, true},
+		{"suffix", config.StringMatcher{Suffix: ".internal"}, []string{"api.internal"}, true},
+		{"regex", config.StringMatcher{Regex: `^v[0-9]+$`}, []string{"v2"}, true},
+		{"regex case-insensitive", config.StringMatcher{Regex: `^v[0-9]+$`, CaseInsensitive: true}, []string{"V2"}, true},
+		{"invalid regex never matches", config.StringMatcher{Regex: `(`}, []string{"("}, false},
+		{"in", config.StringMatcher{In: []string{"eu", "us"}}, []string{"us"}, true},
+		{"in mismatch", config.StringMatcher{In: []string{"eu", "us"}}, []string{"apac"}, false},
+		{"in case-insensitive", config.StringMatcher{In: []string{"eu"}, CaseInsensitive: true}, []string{"EU"}, true},
+		{"present", config.StringMatcher{Present: true}, []string{""}, true},
+		{"present missing", config.StringMatcher{Present: true}, nil, false},
+		{"absent", config.StringMatcher{Absent: true}, nil, true},
+		{"absent but present", config.StringMatcher{Absent: true}, []string{"x"}, false},
+		{"any value matches", config.StringMatcher{Equals: "b"}, []string{"a", "b"}, true},
+		{"operators must hold for the same value", config.StringMatcher{Prefix: "a", Suffix: "z"}, []string{"ab", "yz"}, false},
+		{"combined operators", config.StringMatcher{Prefix: "a", Suffix: "z"}, []string{"abz"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchString(tt.matcher, tt.values))
+		})
+	}
+}
+
+func TestMatchingRules_MultiValued(t *testing.T) {
+	s := &config.Scenario{Matches: config.MatchConfig{
+		Headers: map[string]config.StringMatcher{"Accept": {Contains: "json"}, "X-Tenant": {Absent: true}},
+		Query:   map[string]config.StringMatcher{"tag": {Equals: "beta"}},
+	}}
+
+	req := httptest.NewRequest("GET", "/?tag=alpha&tag=beta", nil)
+	req.Header.Add("Accept", "text/html")
+	req.Header.Add("Accept", "application/json")
+	assert.True(t, matchesRequest(s, req))
+
+	req.Header.Set("X-Tenant", "acme")
+	assert.False(t, matchesRequest(s, req))
+}
+
+func TestMatchBody(t *testing.T) {
+	body := `{"status": "pending", "card": "4111"}`
+	tests := []struct {
+		name     string
+		matcher  config.BodyMatcher
+		expected bool
+	}{
+		{"equals", config.BodyMatcher{Equals: body}, true},
+		{"equals mismatch", config.BodyMatcher{Equals: `{"status": "pending"}`}, false},
+		{"contains", config.BodyMatcher{Contains: `"pending"`}, true},
+		{"notContains", config.BodyMatcher{NotContains: "4111"}, false},
+		{"notContains absent", config.BodyMatcher{NotContains: "5555"}, true},
+		{"regex", config.BodyMatcher{Regex: `"card": "4\d+"`}, true},
+		{"combined", config.BodyMatcher{Contains: "pending", NotContains: "4111"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchBody(&tt.matcher, body))
+		})
+	}
+}
+
+func TestMatchCaller(t *testing.T) {
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("POST", "http://orders.internal:8080/orders", nil)
+		req.RemoteAddr = "10.1.2.3:51234"
+		req.Header.Set("Content-Type", "Application/JSON; charset=utf-8")
+		req.AddCookie(&http.Cookie{Name: "session", Value: "test-42"})
+		return req
+	}
+	withCert := func(r *http.Request) *http.Request {
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "checkout", Organization: []string{"Acme"}}}}}
+		return r
+	}
+	withForwardedFor := func(r *http.Request) *http.Request {
+		r.Header.Set("X-Forwarded-For", "192.168.7.9, 10.1.2.3")
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		matches  config.MatchConfig
+		req      *http.Request
+		expected bool
+	}{
+		{"cookie", config.MatchConfig{Cookies: map[string]config.StringMatcher{"session": {Prefix: "test-"}}}, newRequest(), true},
+		{"cookie mismatch", config.MatchConfig{Cookies: map[string]config.StringMatcher{"session": {Equals: "prod"}}}, newRequest(), false},
+		{"cookie absent", config.MatchConfig{Cookies: map[string]config.StringMatcher{"beta": {Absent: true}}}, newRequest(), true},
+		{"host without port", config.MatchConfig{Host: config.StringMatcher{Equals: "orders.internal"}}, newRequest(), true},
+		{"host mismatch", config.MatchConfig{Host: config.StringMatcher{Suffix: ".example.com"}}, newRequest(), false},
+		{"media type", config.MatchConfig{ContentType: config.StringMatcher{Equals: "application/json"}}, newRequest(), true},
+		{"media type mismatch", config.MatchConfig{ContentType: config.StringMatcher{Equals: "text/plain"}}, newRequest(), false},
+		{"remote address in range", config.MatchConfig{ClientIP: []string{"10.1.0.0/16"}}, newRequest(), true},
+		{"single address", config.MatchConfig{ClientIP: []string{"10.9.9.9", "10.1.2.3"}}, newRequest(), true},
+		{"remote address out of range", config.MatchConfig{ClientIP: []string{"192.168.0.0/16"}}, newRequest(), false},
+		{"forwarded client in range", config.MatchConfig{ClientIP: []string{"192.168.0.0/16"}}, withForwardedFor(newRequest()), true},
+		{"forwarded client takes precedence", config.MatchConfig{ClientIP: []string{"10.1.0.0/16"}}, withForwardedFor(newRequest()), false},
+		{"client certificate", config.MatchConfig{ClientCertSubject: config.StringMatcher{Contains: "CN=checkout"}}, withCert(newRequest()), true},
+		{"no client certificate", config.MatchConfig{ClientCertSubject: config.StringMatcher{Present: true}}, newRequest(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchCaller(&tt.matches, tt.req))
+		})
+	}
+}
+
+// countingReader records whether the request body was read
+type countingReader struct {
+	io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.Reader.Read(p)
+}
+
+func TestMatchingRules_Combinators(t *testing.T) {
+	s := &config.Scenario{Matches: config.MatchConfig{
+		Headers: map[string]config.StringMatcher{"X-Env": {Equals: "test"}},
+		AnyOf: []config.MatchConfig{
+			{Query: map[string]config.StringMatcher{"region": {Equals: "eu"}}},
+			{Body: config.BodyMatcher{Contains: "priority"}},
+		},
+		Not: config.MatchList{{Headers: map[string]config.StringMatcher{"X-Canary": {Present: true}}}},
+	}}
+
+	request := func(target, body string, headers ...string) (*http.Request, *countingReader) {
+		reader := &countingReader{Reader: strings.NewReader(body)}
+		req := httptest.NewRequest("POST", target, reader)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		return req, reader
+	}
+
+	req, reader := request("/?region=eu", "", "X-Env", "test")
+	assert.True(t, matchesRequest(s, req), "First alternative")
+	assert.Zero(t, reader.reads, "Body is not read once an earlier alternative matched")
+
+	req, _ = request("/?region=us", "priority order", "X-Env", "test")
+	assert.True(t, matchesRequest(s, req), "Second alternative")
+	data, _ := io.ReadAll(req.Body)
+	assert.Equal(t, "priority order", string(data), "Body is restored for the handler")
+
+	req, _ = request("/?region=us", "normal order", "X-Env", "test")
+	assert.False(t, matchesRequest(s, req), "No alternative")
+
+	req, _ = request("/?region=eu", "", "X-Env", "test", "X-Canary", "1")
+	assert.False(t, matchesRequest(s, req), "Negated block matches")
+
+	req, reader = request("/?region=us", "priority order", "X-Env", "prod")
+	assert.False(t, matchesRequest(s, req))
+	assert.Zero(t, reader.reads, "Evaluation stops at the first failing matcher")
+
+	nested := &config.Scenario{Matches: config.MatchConfig{AllOf: []config.MatchConfig{
+		{AnyOf: []config.MatchConfig{
+			{Headers: map[string]config.StringMatcher{"A": {Present: true}}},
+			{Headers: map[string]config.StringMatcher{"B": {Present: true}}},
+		}},
+		{Not: config.MatchList{{JSONBody: []config.JSONPathMatcher{{Path: "$.test", Equals: config.JSONValue(`true`)}}}}},
+	}}}
+	req, _ = request("/", `{"test": false}`, "B", "1")
+	assert.True(t, matchesRequest(nested, req))
+	req, _ = request("/", `{"test": true}`, "B", "1")
+	assert.False(t, matchesRequest(nested, req))
+}
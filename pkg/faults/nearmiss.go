@@ -0,0 +1,441 @@
+package faults
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxNearMisses is the number of closest scenarios reported for an unmatched request
+	maxNearMisses = 5
+	// maxUnmatched is the number of unmatched requests kept for /api/control/near-misses
+	maxUnmatched = 50
+	// maxActualLength truncates received bodies in mismatches
+	maxActualLength = 256
+)
+
+// Mismatch describes a single matcher that rejected a request
+type Mismatch struct {
+	Matcher  string `json:"matcher"`            // e.g. method, path or matches.headers.X-Tenant
+	Expected string `json:"expected,omitempty"` // Left out of responses to clients, see PublicNearMisses
+	Actual   string `json:"actual"`
+}
+
+// NearMiss is a scenario that almost matched a request, with the reasons it did not
+type NearMiss struct {
+	ID         string     `json:"id"`
+	Method     string     `json:"method"`
+	Path       string     `json:"path"`
+	Source     string     `json:"source,omitempty"`
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// UnmatchedRequest is a request that no scenario matched
+type UnmatchedRequest struct {
+	Time       time.Time  `json:"time"`
+	Method     string     `json:"method"`
+	Path       string     `json:"path"`
+	NearMisses []NearMiss `json:"nearMisses"`
+}
+
+var (
+	unmatchedMutex sync.Mutex
+	unmatched      []UnmatchedRequest
+)
+
+// recordUnmatched computes the near misses for r and keeps them for UnmatchedRequests
+func recordUnmatched(r *http.Request) UnmatchedRequest {
+	u := UnmatchedRequest{Time: time.Now(), Method: r.Method, Path: r.URL.Path, NearMisses: NearMisses(r)}
+
+	unmatchedMutex.Lock()
+	defer unmatchedMutex.Unlock()
+	if len(unmatched) >= maxUnmatched {
+		unmatched = unmatched[1:]
+	}
+	unmatched = append(unmatched, u)
+	return u
+}
+
+// UnmatchedRequests returns the most recent requests that no scenario matched, newest first
+func UnmatchedRequests() []UnmatchedRequest {
+	unmatchedMutex.Lock()
+	defer unmatchedMutex.Unlock()
+	list := make([]UnmatchedRequest, len(unmatched))
+	for i, u := range unmatched {
+		list[len(unmatched)-1-i] = u
+	}
+	return list
+}
+
+// ResetUnmatchedRequests forgets every recorded unmatched request
+func ResetUnmatchedRequests() {
+	unmatchedMutex.Lock()
+	defer unmatchedMutex.Unlock()
+	unmatched = nil
+}
+
+// PublicNearMisses returns copies of misses without the expected values, for responses to
+// the requesting client. Expected values can hold secrets such as header tokens; they are
+// only reported by /api/control/near-misses.
+func PublicNearMisses(misses []NearMiss) []NearMiss {
+	public := make([]NearMiss, len(misses))
+	for i, miss := range misses {
+		public[i] = miss
+		public[i].Mismatches = make([]Mismatch, len(miss.Mismatches))
+		for j, m := range miss.Mismatches {
+			public[i].Mismatches[j] = Mismatch{Matcher: m.Matcher, Actual: m.Actual}
+		}
+	}
+	return public
+}
+
+// NearMisses returns the scenarios closest to matching r, closest first. Scenarios are
+// considered when their path matches, or when they share the first path segment.
+func NearMisses(r *http.Request) []NearMiss {
+	type scored struct {
+		miss      NearMiss
+		pathMatch bool
+	}
+	var list []scored
+	body := &requestBody{r: r}
+	for _, s := range config.ListScenarios() {
+		var mismatches []Mismatch
+		if s.Disabled {
+			mismatches = append(mismatches, Mismatch{Matcher: "disabled", Expected: "false", Actual: "true"})
+		}
+		if s.WhenState != "" {
+			machine := s.StateMachineName()
+			if state := config.MachineState(machine); state != s.WhenState {
+				mismatches = append(mismatches, Mismatch{Matcher: "whenState", Expected: machine + ": " + s.WhenState, Actual: machine + ": " + state})
+			}
+		}
+		if s.Method != r.Method {
+			mismatches = append(mismatches, Mismatch{Matcher: "method", Expected: s.Method, Actual: r.Method})
+		}
+
+		req := r
+		template, err := config.CompilePathTemplate(s.Path)
+		if err != nil {
+			continue
+		}
+		vars, pathMatch := template.Match(r.URL.Path)
+		if !pathMatch {
+			if firstSegment(s.Path) != firstSegment(r.URL.Path) {
+				continue
+			}
+			mismatches = append(mismatches, Mismatch{Matcher: "path", Expected: s.Path, Actual: r.URL.Path})
+		} else if len(vars) > 0 {
+			req = mux.SetURLVars(r, vars)
+		}
+
+		mismatches = append(mismatches, explainConfig("matches", &s.Matches, req, body)...)
+		if len(mismatches) == 0 {
+			continue // Matches, e.g. when asked about a request that would be served
+		}
+		list = append(list, scored{
+			miss:      NearMiss{ID: s.ID, Method: s.Method, Path: s.Path, Source: s.Source, Mismatches: mismatches},
+			pathMatch: pathMatch,
+		})
+	}
+
+	// A matching path is closer than any number of failed matchers, then fewer mismatches win
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].pathMatch != list[j].pathMatch {
+			return list[i].pathMatch
+		}
+		return len(list[i].miss.Mismatches) < len(list[j].miss.Mismatches)
+	})
+	misses := make([]NearMiss, 0, maxNearMisses)
+	for i := 0; i < len(list) && i < maxNearMisses; i++ {
+		misses = append(misses, list[i].miss)
+	}
+	return misses
+}
+
+func firstSegment(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return segment
+}
+
+// explainConfig returns the matchers of a match block that reject r, mirroring matchConfig
+// but evaluating every matcher instead of stopping at the first failure
+func explainConfig(name string, m *config.MatchConfig, r *http.Request, body *requestBody) []Mismatch {
+	var mismatches []Mismatch
+	explainStrings := func(kind string, matchers map[string]config.StringMatcher, values func(string) []string) {
+		for _, k := range sortedMatcherKeys(matchers) {
+			if v := values(k); !matchString(matchers[k], v) {
+				mismatches = append(mismatches, Mismatch{Matcher: name + "." + kind + "." + k, Expected: describeStringMatcher(matchers[k]), Actual: describeValues(v)})
+			}
+		}
+	}
+	explainStrings("headers", m.Headers, r.Header.Values)
+	query := r.URL.Query()
+	explainStrings("query", m.Query, func(k string) []string { return query[k] })
+	explainStrings("cookies", m.Cookies, func(k string) []string { return cookieValues(r, k) })
+
+	explainString := func(key string, sm config.StringMatcher, values []string) {
+		if !sm.IsZero() && !matchString(sm, values) {
+			mismatches = append(mismatches, Mismatch{Matcher: name + "." + key, Expected: describeStringMatcher(sm), Actual: describeValues(values)})
+		}
+	}
+	explainString("host", m.Host, []string{requestHost(r)})
+	explainString("contentType", m.ContentType, contentTypeValues(r))
+	explainString("clientCertSubject", m.ClientCertSubject, clientCertSubjects(r))
+	if len(m.ClientIP) > 0 && !matchCaller(&config.MatchConfig{ClientIP: m.ClientIP}, r) {
+		actual := describeValues(nil)
+		if ip, ok := clientIP(r); ok {
+			actual = ip.String()
+		}
+		mismatches = append(mismatches, Mismatch{Matcher: name + ".clientIp", Expected: "one of " + strings.Join(m.ClientIP, ", "), Actual: actual})
+	}
+
+	if !m.Body.IsZero() {
+		data, ok := body.bytes()
+		if !ok || !matchBody(&m.Body, string(data)) {
+			mismatches = append(mismatches, Mismatch{Matcher: name + ".body", Expected: describeBodyMatcher(m.Body), Actual: truncate(string(data))})
+		}
+	}
+
+	if len(m.JSONBody) > 0 || len(m.EqualToJSON) > 0 {
+		doc, ok := body.json()
+		data, _ := body.bytes()
+		switch {
+		case !ok:
+			mismatches = append(mismatches, Mismatch{Matcher: name + ".jsonBody", Expected: "a JSON body", Actual: truncate(string(data))})
+		default:
+			for i := range m.JSONBody {
+				jm := &m.JSONBody[i]
+				if !matchJSONPath(jm, doc) {
+					mismatches = append(mismatches, Mismatch{Matcher: fmt.Sprintf("%s.jsonBody[%d]", name, i), Expected: describeJSONPathMatcher(jm), Actual: describeSelected(jm, doc)})
+				}
+			}
+			if len(m.EqualToJSON) > 0 && !matchJSONBody(&config.MatchConfig{EqualToJSON: m.EqualToJSON, IgnoreExtraFields: m.IgnoreExtraFields, IgnoreArrayOrder: m.IgnoreArrayOrder}, doc) {
+				mismatches = append(mismatches, Mismatch{Matcher: name + ".equalToJson", Expected: string(m.EqualToJSON), Actual: truncate(string(data))})
+			}
+		}
+	}
+
+	if !m.GraphQL.IsZero() {
+		mismatches = append(mismatches, explainGraphQL(name+".graphql", &m.GraphQL, body)...)
+	}
+
+	if len(m.Form) > 0 || len(m.Multipart) > 0 {
+		form := body.form()
+		explainStrings("form", m.Form, func(k string) []string { return form.values[k] })
+		for i := range m.Multipart {
+			pm := &m.Multipart[i]
+			if parts := form.partsNamed(pm.Name); !matchParts(pm, parts) {
+				mismatches = append(mismatches, Mismatch{Matcher: fmt.Sprintf("%s.multipart[%d]", name, i), Expected: describePartMatcher(pm), Actual: describeParts(parts)})
+			}
+		}
+	}
+
+	for i := range m.AllOf {
+		mismatches = append(mismatches, explainConfig(fmt.Sprintf("%s.allOf[%d]", name, i), &m.AllOf[i], r, body)...)
+	}
+	if len(m.AnyOf) > 0 {
+		var alternatives []Mismatch
+		for i := range m.AnyOf {
+			blockMismatches := explainConfig(fmt.Sprintf("%s.anyOf[%d]", name, i), &m.AnyOf[i], r, body)
+			if len(blockMismatches) == 0 {
+				alternatives = nil
+				break
+			}
+			alternatives = append(alternatives, blockMismatches...)
+		}
+		mismatches = append(mismatches, alternatives...)
+	}
+	for i := range m.Not {
+		if matchConfig(&m.Not[i], r, body) {
+			mismatches = append(mismatches, Mismatch{Matcher: fmt.Sprintf("%s.not[%d]", name, i), Expected: "block not to match", Actual: "matched"})
+		}
+	}
+	return mismatches
+}
+
+func explainGraphQL(name string, m *config.GraphQLMatcher, body *requestBody) []Mismatch {
+	req, ok := body.graphql()
+	if !ok {
+		data, _ := body.bytes()
+		return []Mismatch{{Matcher: name, Expected: "a GraphQL request", Actual: truncate(string(data))}}
+	}
+	var mismatches []Mismatch
+	if !m.OperationName.IsZero() && !matchString(m.OperationName, optionalValue(req.operationName)) {
+		mismatches = append(mismatches, Mismatch{Matcher: name + ".operationName", Expected: describeStringMatcher(m.OperationName), Actual: describeValues(optionalValue(req.operationName))})
+	}
+	if m.OperationType != "" && req.operationType != m.OperationType {
+		mismatches = append(mismatches, Mismatch{Matcher: name + ".operationType", Expected: m.OperationType, Actual: describeValues(optionalValue(req.operationType))})
+	}
+	for i := range m.Variables {
+		vm := &m.Variables[i]
+		if !matchJSONPath(vm, req.variables) {
+			mismatches = append(mismatches, Mismatch{Matcher: fmt.Sprintf("%s.variables[%d]", name, i), Expected: describeJSONPathMatcher(vm), Actual: describeSelected(vm, req.variables)})
+		}
+	}
+	return mismatches
+}
+
+func sortedMatcherKeys(m map[string]config.StringMatcher) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// describeStringMatcher renders the operators of m, e.g. prefix "Bearer " (case-insensitive)
+func describeStringMatcher(m config.StringMatcher) string {
+	if m.IsZero() {
+		return ""
+	}
+	var ops []string
+	for _, op := range []struct{ name, value string }{
+		{"equals", m.Equals}, {"contains", m.Contains}, {"prefix", m.Prefix}, {"suffix", m.Suffix}, {"regex", m.Regex},
+	} {
+		if op.value != "" {
+			ops = append(ops, fmt.Sprintf("%s %q", op.name, op.value))
+		}
+	}
+	if len(m.In) > 0 {
+		ops = append(ops, fmt.Sprintf("in %q", m.In))
+	}
+	if m.Present {
+		ops = append(ops, "present")
+	}
+	if m.Absent {
+		ops = append(ops, "absent")
+	}
+	description := strings.Join(ops, ", ")
+	if m.CaseInsensitive {
+		description += " (case-insensitive)"
+	}
+	return description
+}
+
+func describeBodyMatcher(m config.BodyMatcher) string {
+	var ops []string
+	for _, op := range []struct{ name, value string }{
+		{"equals", m.Equals}, {"contains", m.Contains}, {"notContains", m.NotContains}, {"regex", m.Regex},
+	} {
+		if op.value != "" {
+			ops = append(ops, fmt.Sprintf("%s %q", op.name, op.value))
+		}
+	}
+	return strings.Join(ops, ", ")
+}
+
+func describeJSONPathMatcher(m *config.JSONPathMatcher) string {
+	ops := []string{m.Path}
+	for _, op := range []struct{ name, value string }{
+		{"equals", string(m.Equals)}, {"contains", string(m.Contains)}, {"gt", string(m.Gt)}, {"gte", string(m.Gte)}, {"lt", string(m.Lt)}, {"lte", string(m.Lte)},
+	} {
+		if op.value != "" {
+			ops = append(ops, op.name+" "+op.value)
+		}
+	}
+	if m.Regex != "" {
+		ops = append(ops, fmt.Sprintf("regex %q", m.Regex))
+	}
+	if m.Exists {
+		ops = append(ops, "exists")
+	}
+	if m.Absent {
+		ops = append(ops, "absent")
+	}
+	return strings.Join(ops, " ")
+}
+
+// describePartMatcher renders a multipart matcher, e.g. part "avatar" filename suffix ".png", maxSize 1048576
+func describePartMatcher(m *config.PartMatcher) string {
+	ops := []string{fmt.Sprintf("part %q", m.Name)}
+	if m.Absent {
+		return ops[0] + " absent"
+	}
+	if !m.Filename.IsZero() {
+		ops = append(ops, "filename "+describeStringMatcher(m.Filename))
+	}
+	if !m.ContentType.IsZero() {
+		ops = append(ops, "contentType "+describeStringMatcher(m.ContentType))
+	}
+	if m.MinSize > 0 {
+		ops = append(ops, fmt.Sprintf("minSize %d", m.MinSize))
+	}
+	if m.MaxSize > 0 {
+		ops = append(ops, fmt.Sprintf("maxSize %d", m.MaxSize))
+	}
+	if !m.Body.IsZero() {
+		ops = append(ops, "body "+describeBodyMatcher(m.Body))
+	}
+	return strings.Join(ops, ", ")
+}
+
+// describeParts renders the received parts with a field name, e.g. "a.png" image/png 2048 bytes
+func describeParts(parts []formPart) string {
+	if len(parts) == 0 {
+		return describeValues(nil)
+	}
+	descriptions := make([]string, len(parts))
+	for i, p := range parts {
+		var fields []string
+		if p.filename != "" {
+			fields = append(fields, fmt.Sprintf("%q", p.filename))
+		}
+		if p.contentType != "" {
+			fields = append(fields, p.contentType)
+		}
+		descriptions[i] = strings.Join(append(fields, fmt.Sprintf("%d bytes", len(p.data))), " ")
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// describeSelected renders the values the matcher's path selects from doc
+func describeSelected(m *config.JSONPathMatcher, doc interface{}) string {
+	path, err := compileJSONPath(m.Path)
+	if err != nil {
+		return err.Error()
+	}
+	values := path.Select(doc)
+	switch len(values) {
+	case 0:
+		return describeValues(nil)
+	case 1:
+		return truncate(jsonText(values[0]))
+	default:
+		data, _ := json.Marshal(values)
+		return truncate(string(data))
+	}
+}
+
+// describeValues renders header, query or cookie values
+func describeValues(values []string) string {
+	switch len(values) {
+	case 0:
+		return "(missing)"
+	case 1:
+		return fmt.Sprintf("%q", values[0])
+	default:
+		return fmt.Sprintf("%q", values)
+	}
+}
+
+// truncate shortens s to at most maxActualLength bytes, without splitting a UTF-8 character
+func truncate(s string) string {
+	if len(s) <= maxActualLength {
+		return s
+	}
+	end := maxActualLength
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + "..."
+}
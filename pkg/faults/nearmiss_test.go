@@ -0,0 +1,125 @@
+package faults
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNearMisses(t *testing.T) {
+	ResetUnmatchedRequests()
+	tenant := config.AddScenario(&config.Scenario{Path: "/nearmiss/orders", Method: "POST",
+		Matches: config.MatchConfig{
+			Headers: map[string]config.StringMatcher{"X-Tenant": {Equals: "acme"}},
+			Query:   map[string]config.StringMatcher{"dryRun": {Absent: true}},
+			Body:    config.BodyMatcher{Regex: `"sku":\s*"OUT-`},
+		},
+		Responses: []config.Response{{Status: 409}}})
+	total := config.AddScenario(&config.Scenario{Path: "/nearmiss/orders", Method: "POST",
+		Matches:   config.MatchConfig{JSONBody: []config.JSONPathMatcher{{Path: "$.total", Gt: "100"}}},
+		Responses: []config.Response{{Status: 402}}})
+	get := config.AddScenario(&config.Scenario{Path: "/nearmiss/orders/{id}", Method: "GET", Responses: []config.Response{{Status: 200}}})
+	config.AddScenario(&config.Scenario{Path: "/elsewhere", Method: "POST", Responses: []config.Response{{Status: 200}}})
+
+	req := httptest.NewRequest("POST", "/nearmiss/orders?dryRun=1", strings.NewReader(`{"sku": "A-1", "total": 20}`))
+	req.Header.Set("X-Tenant", "globex")
+	misses := NearMisses(req)
+	require.Len(t, misses, 3, "Scenarios on other paths are not near misses")
+
+	assert.Equal(t, total.ID, misses[0].ID, "Fewest mismatches first")
+	assert.Equal(t, []Mismatch{{Matcher: "matches.jsonBody[0]", Expected: "$.total gt 100", Actual: "20"}}, misses[0].Mismatches)
+
+	assert.Equal(t, tenant.ID, misses[1].ID)
+	assert.Equal(t, []Mismatch{
+		{Matcher: "matches.headers.X-Tenant", Expected: `equals "acme"`, Actual: `"globex"`},
+		{Matcher: "matches.query.dryRun", Expected: "absent", Actual: `"1"`},
+		{Matcher: "matches.body", Expected: `regex "\"sku\":\\s*\"OUT-"`, Actual: `{"sku": "A-1", "total": 20}`},
+	}, misses[1].Mismatches)
+
+	assert.Equal(t, get.ID, misses[2].ID, "A path mismatch ranks last")
+	assert.Equal(t, []Mismatch{
+		{Matcher: "method", Expected: "GET", Actual: "POST"},
+		{Matcher: "path", Expected: "/nearmiss/orders/{id}", Actual: "/nearmiss/orders"},
+	}, misses[2].Mismatches)
+
+	// The body is still available to the handler
+	w := httptest.NewRecorder()
+	HandleScenario(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Falls back to echo")
+	assert.Equal(t, "true", w.Header().Get("X-Mock-Unmatched"))
+	assert.Equal(t, `{"sku": "A-1", "total": 20}`, w.Body.String())
+	var public []NearMiss
+	require.NoError(t, json.Unmarshal([]byte(w.Header().Get("X-Mock-Near-Misses")), &public))
+	require.Len(t, public, 3)
+	assert.Equal(t, Mismatch{Matcher: "matches.headers.X-Tenant", Actual: `"globex"`}, public[1].Mismatches[0], "Expected values are not sent to the client")
+
+	unmatched := UnmatchedRequests()
+	require.Len(t, unmatched, 1)
+	assert.Equal(t, "/nearmiss/orders", unmatched[0].Path)
+	assert.Len(t, unmatched[0].NearMisses, 3)
+}
+
+func TestNearMisses_NotFound(t *testing.T) {
+	ResetUnmatchedRequests()
+	s := config.AddScenario(&config.Scenario{Path: "/notfound/users/{id:[0-9]+}", Method: "GET", Responses: []config.Response{{Status: 200}}})
+
+	w := httptest.NewRecorder()
+	HandleScenario(w, httptest.NewRequest("GET", "/notfound/users/bob", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var body struct {
+		Error      string     `json:"error"`
+		NearMisses []NearMiss `json:"nearMisses"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "no scenario matches GET /notfound/users/bob", body.Error)
+	require.Len(t, body.NearMisses, 1)
+	assert.Equal(t, s.ID, body.NearMisses[0].ID)
+	assert.Equal(t, "path", body.NearMisses[0].Mismatches[0].Matcher)
+	assert.Empty(t, body.NearMisses[0].Mismatches[0].Expected, "Expected values are not sent to the client")
+
+	unmatched := UnmatchedRequests()
+	require.Len(t, unmatched, 1)
+	assert.Equal(t, s.Path, unmatched[0].NearMisses[0].Mismatches[0].Expected, "but are recorded")
+}
+
+func TestExplainConfig_Combinators(t *testing.T) {
+	m := &config.MatchConfig{
+		AnyOf: []config.MatchConfig{
+			{Headers: map[string]config.StringMatcher{"A": {Present: true}}},
+			{Cookies: map[string]config.StringMatcher{"b": {Prefix: "x", CaseInsensitive: true}}},
+		},
+		Not: config.MatchList{{Host: config.StringMatcher{Equals: "example.com"}}},
+	}
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	assert.Equal(t, []Mismatch{
+		{Matcher: "matches.anyOf[0].headers.A", Expected: "present", Actual: "(missing)"},
+		{Matcher: "matches.anyOf[1].cookies.b", Expected: `prefix "x" (case-insensitive)`, Actual: "(missing)"},
+		{Matcher: "matches.not[0]", Expected: "block not to match", Actual: "matched"},
+	}, explainConfig("matches", m, req, &requestBody{r: req}))
+
+	req.Header.Set("A", "1")
+	assert.Equal(t, []Mismatch{
+		{Matcher: "matches.not[0]", Expected: "block not to match", Actual: "matched"},
+	}, explainConfig("matches", m, req, &requestBody{r: req}), "A matching alternative clears anyOf")
+}
+
+func TestTruncate(t *testing.T) {
+	short := strings.Repeat("é", 10)
+	assert.Equal(t, short, truncate(short))
+
+	// "é" is two bytes, so the limit falls inside a character
+	long := "x" + strings.Repeat("é", maxActualLength)
+	got := truncate(long)
+	assert.True(t, utf8.ValidString(got), "Truncation keeps whole characters")
+	assert.True(t, strings.HasSuffix(got, "..."))
+	assert.LessOrEqual(t, len(got), maxActualLength+len("..."))
+}
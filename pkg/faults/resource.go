@@ -0,0 +1,123 @@
+package faults
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/gorilla/mux"
+)
+
+// resourceResponse returns the first of the resource's faults that fires, or else the result
+// of the operation the request asks for, performed on the collection. It reports whether the
+// response is the result of an operation.
+func resourceResponse(c *config.Collection, r *http.Request) (config.Response, bool) {
+	for _, fault := range c.Resource.Faults {
+		if fault.Probability <= 0 || fault.Probability >= 1 || mrand.Float64() < fault.Probability {
+			return fault, false
+		}
+	}
+	response := performOperation(c, r)
+	response.Delay, response.DelayRange = c.Resource.Delay, c.Resource.DelayRange
+	return response, true
+}
+
+// performOperation lists, gets, creates, replaces, updates or deletes items according to the
+// request method and whether it addresses the collection or a single item
+func performOperation(c *config.Collection, r *http.Request) config.Response {
+	id, isItem := mux.Vars(r)["id"]
+	idField := c.Resource.ItemIDField()
+
+	switch {
+	case !isItem && r.Method == http.MethodGet:
+		return resourceJSON(http.StatusOK, c.List())
+	case !isItem && r.Method == http.MethodPost:
+		item, err := decodeItem(r)
+		if err != nil {
+			return resourceError(http.StatusBadRequest, err.Error())
+		}
+		id, err := c.Create(item)
+		switch {
+		case errors.Is(err, config.ErrDuplicateID):
+			return resourceError(http.StatusConflict, err.Error())
+		case err != nil:
+			return resourceError(http.StatusBadRequest, err.Error())
+		}
+		response := resourceJSON(http.StatusCreated, item)
+		response.Headers["Location"] = c.Resource.Path + "/" + url.PathEscape(id)
+		return response
+	case !isItem:
+		return resourceError(http.StatusMethodNotAllowed, fmt.Sprintf("%s is not supported on a collection", r.Method))
+	}
+
+	existing, ok := c.Get(id)
+	if !ok {
+		return resourceError(http.StatusNotFound, fmt.Sprintf("no item with %s %q", idField, id))
+	}
+	switch r.Method {
+	case http.MethodGet:
+		return resourceJSON(http.StatusOK, existing)
+	case http.MethodPut, http.MethodPatch:
+		item, err := decodeItem(r)
+		if err != nil {
+			return resourceError(http.StatusBadRequest, err.Error())
+		}
+		if v, set := item[idField]; set {
+			if itemID, _ := config.ItemID(v); itemID != id {
+				return resourceError(http.StatusBadRequest, fmt.Sprintf("%s does not match the item path", idField))
+			}
+		}
+		if r.Method == http.MethodPatch {
+			if item, ok = c.Update(id, item); !ok {
+				return resourceError(http.StatusNotFound, fmt.Sprintf("no item with %s %q", idField, id))
+			}
+			return resourceJSON(http.StatusOK, item)
+		}
+		item[idField] = existing[idField]
+		if !c.Replace(id, item) {
+			return resourceError(http.StatusNotFound, fmt.Sprintf("no item with %s %q", idField, id))
+		}
+		return resourceJSON(http.StatusOK, item)
+	case http.MethodDelete:
+		if !c.Delete(id) {
+			return resourceError(http.StatusNotFound, fmt.Sprintf("no item with %s %q", idField, id))
+		}
+		return config.Response{Status: http.StatusNoContent}
+	default:
+		return resourceError(http.StatusMethodNotAllowed, fmt.Sprintf("%s is not supported on an item", r.Method))
+	}
+}
+
+// decodeItem reads an item from a JSON object request body
+func decodeItem(r *http.Request) (map[string]interface{}, error) {
+	if r.Body == nil {
+		return nil, errors.New("request body must be a JSON object")
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	var item map[string]interface{}
+	if err := json.Unmarshal(data, &item); err != nil || item == nil {
+		return nil, errors.New("request body must be a JSON object")
+	}
+	return item, nil
+}
+
+func resourceJSON(status int, v interface{}) config.Response {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return resourceError(http.StatusInternalServerError, err.Error())
+	}
+	return config.Response{Status: status, Body: config.JSONBody(data), Headers: map[string]string{"Content-Type": "application/json"}}
+}
+
+func resourceError(status int, message string) config.Response {
+	data, _ := json.Marshal(map[string]string{"error": message})
+	return config.Response{Status: status, Body: config.JSONBody(data), Headers: map[string]string{"Content-Type": "application/json"}}
+}
@@ -0,0 +1,117 @@
+package faults
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const resourceFile = `
+resources:
+  - path: /fr/users
+    delay: 20ms
+    seed:
+      - id: 1
+        name: Alice
+      - id: 2
+        name: Bob
+  - path: /fr/outage
+    faults:
+      - status: 503
+        body: '{"error": "down"}'
+    circuitBreaker:
+      failureThreshold: 2
+      timeout: 1m
+`
+
+func loadResources(t *testing.T) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "resources.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(resourceFile), 0o644))
+	_, err := config.LoadConfig(file)
+	require.NoError(t, err)
+	config.ResetCollections()
+}
+
+func doResource(method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	HandleScenario(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestResource_Operations(t *testing.T) {
+	loadResources(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"list", "GET", "/fr/users", "", http.StatusOK, `[{"id": 1, "name": "Alice"}, {"id": 2, "name": "Bob"}]`},
+		{"get", "GET", "/fr/users/2", "", http.StatusOK, `{"id": 2, "name": "Bob"}`},
+		{"get missing", "GET", "/fr/users/9", "", http.StatusNotFound, `{"error": "no item with id \"9\""}`},
+		{"create", "POST", "/fr/users", `{"name": "{{.Request.Method}}"}`, http.StatusCreated, `{"id": 3, "name": "{{.Request.Method}}"}`},
+		{"create duplicate", "POST", "/fr/users", `{"id": 1}`, http.StatusConflict, `{"error": "an item with this ID already exists: 1"}`},
+		{"create invalid", "POST", "/fr/users", `[]`, http.StatusBadRequest, `{"error": "request body must be a JSON object"}`},
+		{"replace", "PUT", "/fr/users/1", `{"name": "Alicia"}`, http.StatusOK, `{"id": 1, "name": "Alicia"}`},
+		{"replace other id", "PUT", "/fr/users/1", `{"id": 2}`, http.StatusBadRequest, `{"error": "id does not match the item path"}`},
+		{"replace missing", "PUT", "/fr/users/9", `{}`, http.StatusNotFound, `{"error": "no item with id \"9\""}`},
+		{"update", "PATCH", "/fr/users/2", `{"admin": true}`, http.StatusOK, `{"id": 2, "name": "Bob", "admin": true}`},
+		{"delete", "DELETE", "/fr/users/2", "", http.StatusNoContent, ``},
+		{"delete missing", "DELETE", "/fr/users/2", "", http.StatusNotFound, `{"error": "no item with id \"2\""}`},
+		{"list after changes", "GET", "/fr/users", "", http.StatusOK, `[{"id": 1, "name": "Alicia"}, {"id": 3, "name": "{{.Request.Method}}"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			w := doResource(tt.method, tt.path, tt.body)
+			assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond, "The resource delay applies to every operation")
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantBody == "" {
+				assert.Empty(t, w.Body.String())
+				return
+			}
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+		})
+	}
+
+	w := doResource("POST", "/fr/users", `{"id": "x y"}`)
+	assert.Equal(t, "/fr/users/x%20y", w.Header().Get("Location"))
+}
+
+func TestResource_Faults(t *testing.T) {
+	loadResources(t)
+
+	w := doResource("POST", "/fr/outage", `{"name": "lost"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"error": "down"}`, w.Body.String())
+
+	assert.Equal(t, http.StatusServiceUnavailable, doResource("POST", "/fr/outage", `{}`).Code)
+	w = doResource("POST", "/fr/outage", `{}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "Circuit Breaker Open")
+
+	var items []interface{}
+	require.NoError(t, json.Unmarshal(mustList(t, "/fr/outage"), &items))
+	assert.Empty(t, items, "Faults are served instead of the operation")
+}
+
+func mustList(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := json.Marshal(config.LookupCollection(path).List())
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,140 @@
+package faults
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+)
+
+// Candidate is a scenario whose path and method match a request
+type Candidate struct {
+	Scenario    *config.Scenario
+	Template    string            // Scenario path the request matched
+	Vars        map[string]string // Path variables extracted from the request path
+	Specificity Specificity
+}
+
+// Specificity ranks candidates: a higher priority wins, then more literal path segments
+// (so /users/42 beats /users/{id}), then more request matchers (whenState counts as one)
+type Specificity struct {
+	Priority        int `json:"priority"`
+	LiteralSegments int `json:"literalSegments"`
+	Matchers        int `json:"matchers"`
+}
+
+// Less reports whether a is evaluated after b
+func (a Specificity) Less(b Specificity) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	if a.LiteralSegments != b.LiteralSegments {
+		return a.LiteralSegments < b.LiteralSegments
+	}
+	return a.Matchers < b.Matchers
+}
+
+// candidateIndex holds the scenarios of each method in evaluation order. It is built once
+// per live scenario order, so requests only match their path against each template.
+type candidateIndex struct {
+	order   *config.ScenarioOrder // Order the index was built from
+	methods map[string]*methodCandidates
+}
+
+// methodCandidates are the scenarios of one method in evaluation order, with the distinct
+// path templates they use
+type methodCandidates struct {
+	templates []*config.PathTemplate
+	entries   []indexedCandidate
+}
+
+type indexedCandidate struct {
+	scenario    *config.Scenario
+	template    int // Index into templates
+	specificity Specificity
+}
+
+var currentCandidates atomic.Pointer[candidateIndex]
+
+// candidatesIndex returns the index of the live scenarios, rebuilding it after a change
+func candidatesIndex() *candidateIndex {
+	order := config.LiveScenarioOrder()
+	if idx := currentCandidates.Load(); idx != nil && idx.order == order {
+		return idx
+	}
+	idx := buildCandidateIndex(order)
+	currentCandidates.Store(idx)
+	return idx
+}
+
+func buildCandidateIndex(order *config.ScenarioOrder) *candidateIndex {
+	idx := &candidateIndex{order: order, methods: make(map[string]*methodCandidates)}
+	templates := make(map[string]int) // Method and path to index into templates
+	for _, s := range order.Scenarios {
+		compiled, err := config.CompilePathTemplate(s.Path)
+		if err != nil {
+			continue
+		}
+		mc := idx.methods[s.Method]
+		if mc == nil {
+			mc = &methodCandidates{}
+			idx.methods[s.Method] = mc
+		}
+		key := s.Method + " " + s.Path
+		t, ok := templates[key]
+		if !ok {
+			t = len(mc.templates)
+			templates[key] = t
+			mc.templates = append(mc.templates, compiled)
+		}
+
+		matchers := s.Matches.Count()
+		if s.WhenState != "" {
+			matchers++
+		}
+		mc.entries = append(mc.entries, indexedCandidate{
+			scenario: s,
+			template: t,
+			specificity: Specificity{
+				Priority:        s.Priority,
+				LiteralSegments: compiled.LiteralSegments(),
+				Matchers:        matchers,
+			},
+		})
+	}
+
+	for _, mc := range idx.methods {
+		sort.SliceStable(mc.entries, func(i, j int) bool {
+			return mc.entries[j].specificity.Less(mc.entries[i].specificity)
+		})
+	}
+	return idx
+}
+
+// Candidates returns the scenarios registered for method whose path matches path, in
+// evaluation order. Ties keep the order in which scenarios were loaded or added.
+func Candidates(method, path string) []Candidate {
+	mc := candidatesIndex().methods[method]
+	if mc == nil {
+		return nil
+	}
+
+	vars := make([]map[string]string, len(mc.templates))
+	matched := make([]bool, len(mc.templates))
+	for i, t := range mc.templates {
+		vars[i], matched[i] = t.Match(path)
+	}
+
+	var candidates []Candidate
+	for _, e := range mc.entries {
+		if matched[e.template] {
+			candidates = append(candidates, Candidate{
+				Scenario:    e.scenario,
+				Template:    e.scenario.Path,
+				Vars:        vars[e.template],
+				Specificity: e.specificity,
+			})
+		}
+	}
+	return candidates
+}
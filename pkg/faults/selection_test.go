@@ -0,0 +1,89 @@
+package faults
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCandidates_Order(t *testing.T) {
+	catchAll := config.AddScenario(&config.Scenario{Path: "/order/{id}", Method: "GET", Responses: []config.Response{{Status: 200}}})
+	withHeader := config.AddScenario(&config.Scenario{Path: "/order/{id}", Method: "GET",
+		Matches: config.MatchConfig{Headers: map[string]config.StringMatcher{"X-Tenant": {Equals: "acme"}}}, Responses: []config.Response{{Status: 201}}})
+	exact := config.AddScenario(&config.Scenario{Path: "/order/42", Method: "GET", Responses: []config.Response{{Status: 202}}})
+	pinned := config.AddScenario(&config.Scenario{Path: "/order/{id:[0-9]+}", Method: "GET", Priority: 10,
+		Matches: config.MatchConfig{Query: map[string]config.StringMatcher{"pinned": {Equals: "true"}}}, Responses: []config.Response{{Status: 203}}})
+
+	candidates := Candidates("GET", "/order/42")
+	require.Len(t, candidates, 4)
+	assert.Same(t, pinned, candidates[0].Scenario, "Explicit priority wins")
+	assert.Same(t, exact, candidates[1].Scenario, "Literal path segments beat templates")
+	assert.Same(t, withHeader, candidates[2].Scenario, "More matchers beat fewer")
+	assert.Same(t, catchAll, candidates[3].Scenario)
+	assert.Equal(t, map[string]string{"id": "42"}, candidates[0].Vars)
+
+	assert.Len(t, Candidates("GET", "/order/abc"), 2, "Patterns restrict template matches")
+	assert.Empty(t, Candidates("POST", "/order/42"))
+
+	serve := func(target string, headers map[string]string) int {
+		req := httptest.NewRequest("GET", target, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		HandleScenario(w, req)
+		return w.Code
+	}
+	assert.Equal(t, 203, serve("/order/42?pinned=true", nil))
+	assert.Equal(t, 202, serve("/order/42", nil))
+	assert.Equal(t, 201, serve("/order/7", map[string]string{"X-Tenant": "acme"}), "A catch-all added first does not shadow a specific scenario")
+	assert.Equal(t, 200, serve("/order/7", nil))
+	assert.Equal(t, 404, serve("/order/7/items", nil))
+}
+
+func TestCandidates_Wildcards(t *testing.T) {
+	files := config.AddScenario(&config.Scenario{Path: "/cdn/**", Method: "GET",
+		Responses: []config.Response{{Status: 200, Body: config.JSONBody(`file={{index .Request.PathVars "**"}}`)}}})
+	health := config.AddScenario(&config.Scenario{Path: "/cdn/*/health", Method: "GET",
+		Responses: []config.Response{{Status: 200, Body: config.JSONBody(`health`)}}})
+	image := config.AddScenario(&config.Scenario{Path: "/cdn/images/{name}.png/", Method: "GET",
+		Responses: []config.Response{{Status: 200, Body: config.JSONBody(`image={{.Request.PathVars.name}}`)}}})
+
+	candidates := Candidates("GET", "/cdn/eu/health")
+	require.Len(t, candidates, 2)
+	assert.Same(t, health, candidates[0].Scenario, "More literal segments beat a catch-all")
+	assert.Same(t, files, candidates[1].Scenario)
+
+	candidates = Candidates("GET", "/cdn/images/logo.png")
+	require.Len(t, candidates, 2)
+	assert.Same(t, image, candidates[0].Scenario)
+
+	serve := func(target string) string {
+		w := httptest.NewRecorder()
+		HandleScenario(w, httptest.NewRequest("GET", target, nil))
+		return w.Body.String()
+	}
+	assert.Equal(t, "file=js/app.js", serve("/cdn/js/app.js"))
+	assert.Equal(t, "image=logo", serve("/cdn/images/logo.png"), "Trailing slash in the template is optional")
+	assert.Equal(t, "health", serve("/cdn/eu/health/"), "Trailing slash in the request is optional")
+}
+
+func TestCandidates_TiesKeepInsertionOrder(t *testing.T) {
+	// Paths sort the other way round, so lexical order would put "second" first
+	first := config.AddScenario(&config.Scenario{Path: "/tie/{z}", Method: "GET", Responses: []config.Response{{Status: 200}}})
+	second := config.AddScenario(&config.Scenario{Path: "/tie/{a}", Method: "GET", Responses: []config.Response{{Status: 201}}})
+
+	candidates := Candidates("GET", "/tie/1")
+	require.Len(t, candidates, 2)
+	assert.Same(t, first, candidates[0].Scenario)
+	assert.Same(t, second, candidates[1].Scenario)
+
+	// The index follows changes to the live set
+	require.True(t, config.DeleteScenario(first.ID))
+	candidates = Candidates("GET", "/tie/1")
+	require.Len(t, candidates, 1)
+	assert.Same(t, second, candidates[0].Scenario)
+}
@@ -0,0 +1,74 @@
+package faults
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequenceKey(t *testing.T) {
+	config.AddScenario(&config.Scenario{Path: "/seqkey/flaky", Method: "GET",
+		Strategy:    config.StrategyStickyLast,
+		SequenceKey: config.SequenceKey{Header: "X-Shard"},
+		Responses:   []config.Response{{Status: 503}, {Status: 503}, {Status: 200}}})
+
+	status := func(shard string) int {
+		req := httptest.NewRequest("GET", "/seqkey/flaky", nil)
+		if shard != "" {
+			req.Header.Set("X-Shard", shard)
+		}
+		w := httptest.NewRecorder()
+		HandleScenario(w, req)
+		return w.Code
+	}
+
+	// Interleaved shards each see the whole sequence
+	assert.Equal(t, 503, status("a"))
+	assert.Equal(t, 503, status("b"))
+	assert.Equal(t, 503, status("a"))
+	assert.Equal(t, 200, status("a"))
+	assert.Equal(t, 503, status("b"))
+	assert.Equal(t, 200, status("b"))
+
+	// Requests without the key share the scenario's sequence
+	assert.Equal(t, 503, status(""))
+	assert.Equal(t, 503, status(""))
+	assert.Equal(t, 200, status(""))
+}
+
+func TestSequenceKey_CircuitBreaker(t *testing.T) {
+	s := config.AddScenario(&config.Scenario{Path: "/seqkey/tenants/{tenant}", Method: "GET",
+		SequenceKey:    config.SequenceKey{PathVar: "tenant"},
+		CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Minute},
+		Responses:      []config.Response{{Status: 500}}})
+
+	status := func(tenant string) int {
+		w := httptest.NewRecorder()
+		HandleScenario(w, httptest.NewRequest("GET", "/seqkey/tenants/"+tenant, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, status("acme"))
+	assert.Equal(t, http.StatusServiceUnavailable, status("acme"), "acme's breaker is open")
+	assert.Equal(t, http.StatusInternalServerError, status("globex"), "globex has its own breaker")
+	assert.Equal(t, "closed", s.Runtime.CBState.State, "The scenario's own breaker is untouched")
+
+	config.ResetSequence(s.ID, "acme")
+	assert.Equal(t, http.StatusInternalServerError, status("acme"))
+}
+
+func TestSequenceKeyValue(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?shard=q", nil)
+	req.Header.Set("X-Shard", "h")
+	req.AddCookie(&http.Cookie{Name: "shard", Value: "c"})
+
+	assert.Equal(t, "h", sequenceKeyValue(config.SequenceKey{Header: "X-Shard"}, req))
+	assert.Equal(t, "c", sequenceKeyValue(config.SequenceKey{Cookie: "shard"}, req))
+	assert.Equal(t, "q", sequenceKeyValue(config.SequenceKey{Query: "shard"}, req))
+	assert.Equal(t, "", sequenceKeyValue(config.SequenceKey{Cookie: "missing"}, req))
+	assert.Equal(t, "", sequenceKeyValue(config.SequenceKey{}, req))
+}
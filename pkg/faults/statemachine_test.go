@@ -0,0 +1,72 @@
+package faults
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateMachine_AcrossEndpoints(t *testing.T) {
+	config.AddScenario(&config.Scenario{Path: "/sm/orders", Method: "POST", StateMachine: "sm-orders", SetState: "created",
+		Responses: []config.Response{{Status: 201}}})
+	config.AddScenario(&config.Scenario{Path: "/sm/orders/{id}", Method: "GET", StateMachine: "sm-orders", WhenState: "created",
+		Responses: []config.Response{{Status: 200, Body: config.JSONBody(`order {{.Request.PathVars.id}} is {{index .State "sm-orders"}}`)}}})
+	config.AddScenario(&config.Scenario{Path: "/sm/orders/{id}", Method: "GET",
+		Responses: []config.Response{{Status: 404}}})
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		HandleScenario(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, do("GET", "/sm/orders/7").Code)
+	assert.Equal(t, http.StatusCreated, do("POST", "/sm/orders").Code)
+	assert.Equal(t, "created", config.MachineState("sm-orders"))
+
+	w := do("GET", "/sm/orders/7")
+	assert.Equal(t, http.StatusOK, w.Code, "The whenState scenario now matches and ranks first")
+	assert.Equal(t, "order 7 is created", w.Body.String())
+
+	config.ResetMachineStates("sm-orders")
+	assert.Equal(t, http.StatusNotFound, do("GET", "/sm/orders/7").Code)
+}
+
+func TestStateMachine_ResponseSetState(t *testing.T) {
+	config.AddScenario(&config.Scenario{Path: "/sm/login", Method: "POST", StateMachine: "sm-login", Strategy: config.StrategyStickyLast,
+		Responses: []config.Response{{Status: 401}, {Status: 401}, {Status: 401, SetState: "locked"}}})
+	config.AddScenario(&config.Scenario{Path: "/sm/account", Method: "GET", StateMachine: "sm-login", WhenState: "locked",
+		Responses: []config.Response{{Status: 423, Body: config.JSONBody(`{{index .State "sm-login"}}`)}}})
+	config.AddScenario(&config.Scenario{Path: "/sm/account", Method: "GET",
+		Responses: []config.Response{{Status: 200}}})
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		HandleScenario(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, do("POST", "/sm/login").Code)
+		assert.Equal(t, http.StatusOK, do("GET", "/sm/account").Code)
+	}
+	assert.Equal(t, http.StatusUnauthorized, do("POST", "/sm/login").Code)
+
+	w := do("GET", "/sm/account")
+	assert.Equal(t, http.StatusLocked, w.Code)
+	assert.Equal(t, "locked", w.Body.String())
+}
+
+func TestNearMisses_WhenState(t *testing.T) {
+	s := config.AddScenario(&config.Scenario{Path: "/sm-nearmiss/cart", Method: "GET", StateMachine: "sm-cart", WhenState: "filled",
+		Responses: []config.Response{{Status: 200}}})
+
+	misses := NearMisses(httptest.NewRequest("GET", "/sm-nearmiss/cart", nil))
+	if assert.Len(t, misses, 1) {
+		assert.Equal(t, s.ID, misses[0].ID)
+		assert.Equal(t, []Mismatch{{Matcher: "whenState", Expected: "sm-cart: filled", Actual: "sm-cart: initial"}}, misses[0].Mismatches)
+	}
+}
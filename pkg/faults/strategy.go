@@ -0,0 +1,143 @@
+package faults
+
+import (
+	mrand "math/rand"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/gorilla/mux"
+)
+
+// sequence is the state a request advances: the scenario's own, or that of its sequence key
+type sequence struct {
+	index   *int32
+	cb      *config.CircuitBreakerState
+	shuffle *config.ShuffleState
+}
+
+// sequenceFor returns the sequence of r through s. Requests without a value for the
+// scenario's sequenceKey share the scenario's own sequence.
+func sequenceFor(s *config.Scenario, r *http.Request) sequence {
+	st := &s.Runtime.SequenceState
+	if key := sequenceKeyValue(s.SequenceKey, r); key != "" {
+		st = s.Runtime.Sequences.Get(key)
+	}
+	return sequence{index: &st.Index, cb: st.CBState, shuffle: st.Shuffle}
+}
+
+// sequenceKeyValue returns the value of the sequence key in r, or "" if it has none
+func sequenceKeyValue(k config.SequenceKey, r *http.Request) string {
+	switch {
+	case k.Header != "":
+		return r.Header.Get(k.Header)
+	case k.Cookie != "":
+		if c, err := r.Cookie(k.Cookie); err == nil {
+			return c.Value
+		}
+	case k.Query != "":
+		return r.URL.Query().Get(k.Query)
+	case k.PathVar != "":
+		return mux.Vars(r)[k.PathVar]
+	}
+	return ""
+}
+
+// selectResponse returns the index of the response to serve next, according to the
+// scenario's strategy. It reports false once a sequence that does not loop or stick to its
+// last response is exhausted. It is safe for concurrent use: every caller gets its own step.
+func selectResponse(s *config.Scenario, seq sequence) (int, bool) {
+	switch s.Strategy {
+	case config.StrategyRandom:
+		return mrand.Intn(len(s.Responses)), true
+	case config.StrategyWeighted:
+		return selectWeighted(s.Responses), true
+	case config.StrategyShuffle:
+		return selectShuffled(seq.shuffle, len(s.Responses)), true
+	}
+
+	// The index counts steps, so a response with times: N takes N steps
+	steps := sequenceSteps(s.Responses)
+	var step int
+	switch {
+	case s.Strategy == config.StrategyStickyLast || s.OnExhausted == config.ExhaustedStickLast:
+		step = advanceIndex(seq.index, func(idx int32) int32 { return min(idx+1, steps-1) }, steps)
+	case s.OnExhausted == config.ExhaustedFallthrough || s.OnExhausted == config.ExhaustedNotFound:
+		// Stops one past the last step, which marks the sequence as exhausted
+		if step = advanceIndex(seq.index, func(idx int32) int32 { return min(idx+1, steps) }, steps+1); step == int(steps) {
+			return 0, false
+		}
+	default:
+		step = advanceIndex(seq.index, func(idx int32) int32 { return (idx + 1) % steps }, steps)
+	}
+	return responseAt(s.Responses, step), true
+}
+
+// sequenceSteps returns the length of a sequence, counting each response times times
+func sequenceSteps(responses []config.Response) int32 {
+	var steps int32
+	for i := range responses {
+		steps += int32(max(responses[i].Times, 1))
+	}
+	return steps
+}
+
+// responseAt returns the index of the response served at a step of the sequence
+func responseAt(responses []config.Response, step int) int {
+	for i := range responses {
+		if step -= max(responses[i].Times, 1); step < 0 {
+			return i
+		}
+	}
+	return len(responses) - 1
+}
+
+// advanceIndex atomically moves index to next(index) and returns the index it moved from
+func advanceIndex(index *int32, next func(int32) int32, n int32) int {
+	for {
+		current := atomic.LoadInt32(index)
+		idx := current
+		if idx < 0 || idx >= n {
+			idx = 0 // Set while the scenario had more responses
+		}
+		if atomic.CompareAndSwapInt32(index, current, next(idx)) {
+			return int(idx)
+		}
+	}
+}
+
+// selectWeighted picks a response with probability proportional to its weight (default 1)
+func selectWeighted(responses []config.Response) int {
+	weight := func(r *config.Response) int {
+		if r.Weight > 0 {
+			return r.Weight
+		}
+		return 1
+	}
+	total := 0
+	for i := range responses {
+		total += weight(&responses[i])
+	}
+	pick := mrand.Intn(total)
+	for i := range responses {
+		if pick -= weight(&responses[i]); pick < 0 {
+			return i
+		}
+	}
+	return len(responses) - 1
+}
+
+// selectShuffled serves every response once per round, in a new random order each round
+func selectShuffled(state *config.ShuffleState, n int) int {
+	if state == nil {
+		return mrand.Intn(n) // Scenario not added through the store
+	}
+	state.Mutex.Lock()
+	defer state.Mutex.Unlock()
+	if len(state.Order) == 0 {
+		state.Order = mrand.Perm(n)
+	}
+	idx := state.Order[0]
+	state.Order = state.Order[1:]
+	return idx
+}
@@ -0,0 +1,166 @@
+package faults
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func strategyScenario(strategy string, weights ...int) *config.Scenario {
+	s := &config.Scenario{Strategy: strategy, Runtime: config.NewScenarioRuntime()}
+	for _, w := range weights {
+		s.Responses = append(s.Responses, config.Response{Status: 200, Weight: w})
+	}
+	return s
+}
+
+// nextResponse selects the next response of s, or -1 once its sequence is exhausted
+func nextResponse(s *config.Scenario) int {
+	idx, ok := selectResponse(s, sequenceFor(s, nil))
+	if !ok {
+		return -1
+	}
+	return idx
+}
+
+// selectConcurrently selects n responses from 8 goroutines and counts each index, not
+// counting selections after the sequence is exhausted
+func selectConcurrently(s *config.Scenario, n int) []int {
+	counts := make([]int, len(s.Responses))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n/8; i++ {
+				idx := nextResponse(s)
+				if idx < 0 {
+					continue
+				}
+				mu.Lock()
+				counts[idx]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return counts
+}
+
+func TestSelectResponse_Sequential(t *testing.T) {
+	s := strategyScenario("", 0, 0, 0)
+	assert.Equal(t, []int{0, 1, 2, 0}, []int{nextResponse(s), nextResponse(s), nextResponse(s), nextResponse(s)})
+
+	s = strategyScenario(config.StrategySequential, 0, 0, 0, 0)
+	assert.Equal(t, []int{2000, 2000, 2000, 2000}, selectConcurrently(s, 8000), "Concurrent callers never skip or repeat a step")
+	assert.Equal(t, int32(0), s.Runtime.Index)
+
+	s.Runtime.Index = 7 // e.g. restored after responses were removed
+	assert.Equal(t, 0, nextResponse(s))
+	assert.Equal(t, 1, nextResponse(s))
+}
+
+func TestSelectResponse_StickyLast(t *testing.T) {
+	s := strategyScenario(config.StrategyStickyLast, 0, 0, 0)
+	var got []int
+	for i := 0; i < 5; i++ {
+		got = append(got, nextResponse(s))
+	}
+	assert.Equal(t, []int{0, 1, 2, 2, 2}, got)
+
+	s = strategyScenario(config.StrategyStickyLast, 0, 0, 0)
+	assert.Equal(t, []int{1, 1, 798}, selectConcurrently(s, 800))
+}
+
+func TestSelectResponse_Times(t *testing.T) {
+	responses := []config.Response{{Status: 503, Times: 3}, {Status: 200}, {Status: 500, Times: 2}}
+	tests := []struct {
+		strategy    string
+		onExhausted string
+		want        []int
+	}{
+		{"", "", []int{0, 0, 0, 1, 2, 2, 0, 0}},
+		{"", config.ExhaustedLoop, []int{0, 0, 0, 1, 2, 2, 0, 0}},
+		{"", config.ExhaustedStickLast, []int{0, 0, 0, 1, 2, 2, 2, 2}},
+		{config.StrategyStickyLast, "", []int{0, 0, 0, 1, 2, 2, 2, 2}},
+		{config.StrategySequential, config.ExhaustedFallthrough, []int{0, 0, 0, 1, 2, 2, -1, -1}},
+		{"", config.ExhaustedNotFound, []int{0, 0, 0, 1, 2, 2, -1, -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy+"/"+tt.onExhausted, func(t *testing.T) {
+			s := &config.Scenario{Strategy: tt.strategy, OnExhausted: tt.onExhausted, Responses: responses, Runtime: config.NewScenarioRuntime()}
+			var got []int
+			for range tt.want {
+				got = append(got, nextResponse(s))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	s := &config.Scenario{OnExhausted: config.ExhaustedNotFound, Responses: []config.Response{{Status: 503, Times: 100}, {Status: 200, Times: 700}}, Runtime: config.NewScenarioRuntime()}
+	assert.Equal(t, []int{100, 700}, selectConcurrently(s, 1600), "Concurrent callers share the bounded sequence")
+}
+
+func TestSelectResponse_Random(t *testing.T) {
+	counts := selectConcurrently(strategyScenario(config.StrategyRandom, 0, 0), 8000)
+	assert.InDelta(t, 4000, counts[0], 400)
+	assert.InDelta(t, 4000, counts[1], 400)
+}
+
+func TestSelectResponse_Weighted(t *testing.T) {
+	counts := selectConcurrently(strategyScenario(config.StrategyWeighted, 8, 0, 1), 16000)
+	assert.InDelta(t, 12800, counts[0], 800, "Weight 8 of 10")
+	assert.InDelta(t, 1600, counts[1], 400, "Weight defaults to 1")
+	assert.InDelta(t, 1600, counts[2], 400)
+}
+
+func TestSelectResponse_Shuffle(t *testing.T) {
+	s := strategyScenario(config.StrategyShuffle, 0, 0, 0, 0, 0)
+	for round := 0; round < 20; round++ {
+		seen := map[int]bool{}
+		for i := 0; i < 5; i++ {
+			seen[nextResponse(s)] = true
+		}
+		assert.Len(t, seen, 5, "Every response is served once per round")
+	}
+
+	assert.Equal(t, []int{1000, 1000, 1000, 1000, 1000}, selectConcurrently(s, 5000))
+}
+
+func TestHandleScenario_OnExhausted(t *testing.T) {
+	config.AddScenario(&config.Scenario{Path: "/exhausted/recover", Method: "GET", OnExhausted: config.ExhaustedFallthrough, Priority: 1,
+		Responses: []config.Response{{Status: 503, Times: 2}}})
+	config.AddScenario(&config.Scenario{Path: "/exhausted/recover", Method: "GET",
+		Responses: []config.Response{{Status: 200}}})
+	config.AddScenario(&config.Scenario{Path: "/exhausted/echo", Method: "GET", OnExhausted: config.ExhaustedFallthrough,
+		Responses: []config.Response{{Status: 503}}})
+	notFound := config.AddScenario(&config.Scenario{Path: "/exhausted/gone", Method: "GET", OnExhausted: config.ExhaustedNotFound,
+		Responses: []config.Response{{Status: 200}}})
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		HandleScenario(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	var got []int
+	for i := 0; i < 5; i++ {
+		got = append(got, do("/exhausted/recover").Code)
+	}
+	assert.Equal(t, []int{503, 503, 200, 200, 200}, got, "Falls through to the next matching scenario")
+
+	assert.Equal(t, http.StatusServiceUnavailable, do("/exhausted/echo").Code)
+	w := do("/exhausted/echo")
+	assert.Equal(t, http.StatusOK, w.Code, "Falls through to echo")
+	assert.Empty(t, w.Header().Get("X-Mock-Unmatched"), "The request matched a scenario")
+
+	assert.Equal(t, http.StatusOK, do("/exhausted/gone").Code)
+	w = do("/exhausted/gone")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error": "scenario `+notFound.ID+` has served every response"}`, w.Body.String())
+	assert.Equal(t, uint64(2), notFound.Runtime.Hits)
+}
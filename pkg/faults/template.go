@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/arun0009/go-resilience-mock/pkg/config"
 	"github.com/gorilla/mux"
 )
 
@@ -23,12 +24,22 @@ type TemplateData struct {
 		Query    map[string]string
 		Headers  map[string]string
 		PathVars map[string]string
-		Body     interface{} // Parsed JSON or raw string
+		Body     interface{}             // Parsed JSON or raw string
+		Form     map[string]string       // Form-encoded or multipart fields (flattened)
+		Files    map[string]UploadedFile // Multipart file parts by field name (first file)
 	}
 	Server struct {
 		Hostname  string
 		Timestamp string
 	}
+	State map[string]string // Current state of each state machine, e.g. {{.State.orders}}
+}
+
+// UploadedFile describes a file part of a multipart request body
+type UploadedFile struct {
+	Filename    string
+	ContentType string
+	Size        int
 }
 
 // executeTemplate renders the response body as a Go template.
@@ -69,6 +80,8 @@ func executeTemplate(body string, r *http.Request) (string, error) {
 	data.Request.PathVars = mux.Vars(r)
 
 	// Request Body
+	data.Request.Form = make(map[string]string)
+	data.Request.Files = make(map[string]UploadedFile)
 	if r.Body != nil {
 		var bodyBuf bytes.Buffer
 		_, _ = bodyBuf.ReadFrom(r.Body)
@@ -89,6 +102,17 @@ func executeTemplate(body string, r *http.Request) (string, error) {
 			data.Request.Body = bodyStr
 		}
 
+		// Form fields and uploaded files (flattened)
+		form := parseForm(contentType, bodyBuf.Bytes())
+		for k, v := range form.values {
+			data.Request.Form[k] = v[0]
+		}
+		for _, p := range form.parts {
+			if _, seen := data.Request.Files[p.name]; p.filename != "" && !seen {
+				data.Request.Files[p.name] = UploadedFile{Filename: p.filename, ContentType: p.contentType, Size: len(p.data)}
+			}
+		}
+
 		// Restore body for subsequent readers
 		r.Body = io.NopCloser(&bodyBuf)
 	}
@@ -101,6 +125,9 @@ func executeTemplate(body string, r *http.Request) (string, error) {
 		data.Server.Hostname = "unknown"
 	}
 
+	// State Machines
+	data.State = config.MachineStates()
+
 	// 2. Parse and Execute with custom functions
 	funcMap := template.FuncMap{
 		"uuid": func() string {
@@ -105,6 +105,28 @@ func TestExecuteTemplate(t *testing.T) {
 			expected: "Received: Hello World",
 			wantErr:  false,
 		},
+		{
+			name:     "Form Body",
+			body:     "Plan: {{.Request.Form.plan}}",
+			method:   "POST",
+			path:     "/api/subscribe",
+			query:    nil,
+			headers:  http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}},
+			reqBody:  "plan=pro&seats=5",
+			expected: "Plan: pro",
+			wantErr:  false,
+		},
+		{
+			name:     "Multipart Upload",
+			body:     "{{.Request.Form.title}}: {{.Request.Files.doc.Filename}} ({{.Request.Files.doc.ContentType}}, {{.Request.Files.doc.Size}} bytes)",
+			method:   "POST",
+			path:     "/api/upload",
+			query:    nil,
+			headers:  http.Header{"Content-Type": []string{"multipart/form-data; boundary=XYZ"}},
+			reqBody:  multipartBody,
+			expected: "Q3: report.pdf (application/pdf, 8 bytes)",
+			wantErr:  false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,192 @@
+// Package jsonpath implements the subset of JSONPath used to match request bodies:
+//
+//	$                  the document root
+//	.name, ['name']    an object member
+//	[0], [-1]          an array element, counted from the end when negative
+//	.*, [*]            every member or element
+//	..name, ..*        recursive descent
+//
+// Documents are values decoded by encoding/json into interface{}.
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled JSONPath expression
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// step selects values relative to each value selected so far
+type step struct {
+	recursive bool   // Apply to every descendant as well (..)
+	wildcard  bool   // Select every member or element (*)
+	name      string // Object member
+	index     int    // Array element, when isIndex is set
+	isIndex   bool
+}
+
+// Parse compiles a JSONPath expression. The leading $ is optional, so items[0].sku
+// is the same as $.items[0].sku.
+func Parse(expr string) (*Path, error) {
+	p := &Path{expr: expr}
+	s := strings.TrimSpace(expr)
+	if strings.HasPrefix(s, "$") {
+		s = s[1:]
+	} else if s != "" && s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+
+	for s != "" {
+		var st step
+		switch {
+		case strings.HasPrefix(s, ".."):
+			st.recursive = true
+			s = s[2:]
+			if strings.HasPrefix(s, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(s, "."):
+			s = strings.TrimPrefix(s, ".")
+			name := s
+			if i := strings.IndexAny(s, ".["); i >= 0 {
+				name = s[:i]
+			}
+			if name == "" {
+				return nil, fmt.Errorf("jsonpath %q: missing member name", expr)
+			}
+			s = s[len(name):]
+			if name == "*" {
+				st.wildcard = true
+			} else {
+				st.name = name
+			}
+			p.steps = append(p.steps, st)
+			continue
+		case !strings.HasPrefix(s, "["):
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q", expr, s)
+		}
+
+		end := closingBracket(s)
+		if end < 0 {
+			return nil, fmt.Errorf("jsonpath %q: missing ]", expr)
+		}
+		selector := strings.TrimSpace(s[1:end])
+		s = s[end+1:]
+		switch {
+		case selector == "*":
+			st.wildcard = true
+		case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+			st.name = selector[1 : len(selector)-1]
+		default:
+			i, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath %q: unsupported selector [%s]", expr, selector)
+			}
+			st.index, st.isIndex = i, true
+		}
+		p.steps = append(p.steps, st)
+	}
+	return p, nil
+}
+
+// closingBracket returns the index of the ] that closes the [ at s[0], skipping quoted names
+func closingBracket(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// String returns the expression the path was parsed from
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Select returns every value in doc the path refers to, in document order.
+// Object members are visited in key order for wildcards and recursive descent.
+func (p *Path) Select(doc interface{}) []interface{} {
+	values := []interface{}{doc}
+	for _, st := range p.steps {
+		var next []interface{}
+		for _, v := range values {
+			if st.recursive {
+				for _, d := range descendants(v) {
+					next = st.apply(d, next)
+				}
+				continue
+			}
+			next = st.apply(v, next)
+		}
+		values = next
+	}
+	return values
+}
+
+// apply appends the values st selects from v to out
+func (st step) apply(v interface{}, out []interface{}) []interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if st.wildcard {
+			for _, k := range sortedKeys(v) {
+				out = append(out, v[k])
+			}
+		} else if child, ok := v[st.name]; ok && !st.isIndex {
+			out = append(out, child)
+		}
+	case []interface{}:
+		if st.wildcard {
+			out = append(out, v...)
+		} else if st.isIndex {
+			i := st.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				out = append(out, v[i])
+			}
+		}
+	}
+	return out
+}
+
+// descendants returns v and every value nested in it
+func descendants(v interface{}) []interface{} {
+	out := []interface{}{v}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			out = append(out, descendants(v[k])...)
+		}
+	case []interface{}:
+		for _, e := range v {
+			out = append(out, descendants(e)...)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
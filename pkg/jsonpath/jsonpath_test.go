@@ -0,0 +1,64 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const order = `{
+	"id": "o-1",
+	"total": 120.5,
+	"customer": {"id": 7, "tier": "gold"},
+	"items": [
+		{"sku": "A-1", "qty": 1},
+		{"sku": "OUT-OF-STOCK", "qty": 2, "tags": ["sale"]}
+	],
+	"odd key": true
+}`
+
+func TestSelect(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(order), &doc))
+
+	tests := []struct {
+		expr     string
+		expected []interface{}
+	}{
+		{"$", []interface{}{doc}},
+		{"$.id", []interface{}{"o-1"}},
+		{"id", []interface{}{"o-1"}},
+		{"$.customer.tier", []interface{}{"gold"}},
+		{"$['customer']['id']", []interface{}{float64(7)}},
+		{`$["odd key"]`, []interface{}{true}},
+		{"$.items[0].sku", []interface{}{"A-1"}},
+		{"$.items[-1].sku", []interface{}{"OUT-OF-STOCK"}},
+		{"$.items[*].sku", []interface{}{"A-1", "OUT-OF-STOCK"}},
+		{"$.items.*.qty", []interface{}{float64(1), float64(2)}},
+		{"$..sku", []interface{}{"A-1", "OUT-OF-STOCK"}},
+		{"$..tags[0]", []interface{}{"sale"}},
+		{"$.customer.*", []interface{}{float64(7), "gold"}},
+		{"$.missing", nil},
+		{"$.items[5]", nil},
+		{"$.id[0]", nil},
+		{"$.items.sku", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, p.Select(doc))
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, expr := range []string{"$.", "$..", "$.items[", "$.items[?(@.qty > 1)]", "$.items[1:2]", "$items"} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := Parse(expr)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -36,9 +36,36 @@ var (
 		[]string{"path", "method", "status"},
 	)
 
+	// ScenarioReloads counts hot reloads of the scenario file, labeled by result (success, failure)
+	ScenarioReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mock_scenario_reloads_total",
+			Help: "Total number of scenario file reloads, labeled by result (success, failure).",
+		},
+		[]string{"result"},
+	)
+
+	// ActiveProfile reports the active scenario profile as a gauge with value 1
+	ActiveProfile prometheus.Collector = activeProfileCollector{
+		desc: prometheus.NewDesc("mock_active_profile", "Active scenario profile (value is always 1).", []string{"profile"}, nil),
+	}
+
 	initOnce sync.Once
 )
 
+// activeProfileCollector reads the active profile at scrape time, so it never goes stale
+type activeProfileCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c activeProfileCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c activeProfileCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1, config.ActiveProfile())
+}
+
 // InitMetrics registers all custom Prometheus collectors.
 // InitMetrics registers all custom Prometheus collectors.
 func InitMetrics() {
@@ -47,5 +74,7 @@ func InitMetrics() {
 		reg.MustRegister(FaultsInjected)
 		reg.MustRegister(InflightRequests)
 		reg.MustRegister(ResponseDuration)
+		reg.MustRegister(ScenarioReloads)
+		reg.MustRegister(ActiveProfile)
 	})
 }
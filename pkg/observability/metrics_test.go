@@ -1,8 +1,11 @@
 package observability
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,3 +16,12 @@ func TestInitMetrics(t *testing.T) {
 		InitMetrics()
 	}, "InitMetrics should not panic")
 }
+
+func TestActiveProfileMetric(t *testing.T) {
+	expected := `
+# HELP mock_active_profile Active scenario profile (value is always 1).
+# TYPE mock_active_profile gauge
+mock_active_profile{profile="default"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(ActiveProfile, strings.NewReader(expected)))
+}
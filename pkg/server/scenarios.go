@@ -0,0 +1,432 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/arun0009/go-resilience-mock/pkg/faults"
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// --- Scenario Admin API ---
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// decodeScenarios parses a JSON scenario object or array. It also returns the YAML node of
+// each scenario (YAML is a superset of JSON) so problems can be reported with positions.
+func decodeScenarios(body []byte) ([]config.Scenario, []*yaml.Node, error) {
+	var scenarios []config.Scenario
+
+	// Try parsing as a list of scenarios first
+	if err := json.Unmarshal(body, &scenarios); err != nil {
+		// Try parsing as a single scenario
+		var s config.Scenario
+		if err2 := json.Unmarshal(body, &s); err2 != nil {
+			return nil, nil, err2
+		}
+		scenarios = []config.Scenario{s}
+	}
+
+	var nodes []*yaml.Node
+	var root yaml.Node
+	if err := yaml.Unmarshal(body, &root); err == nil && len(root.Content) > 0 {
+		if doc := root.Content[0]; doc.Kind == yaml.SequenceNode {
+			nodes = doc.Content
+		} else {
+			nodes = []*yaml.Node{doc}
+		}
+	}
+
+	for i := range scenarios {
+		// Origin is tracked by the server, not taken from the request
+		scenarios[i].Source = ""
+		scenarios[i].Profile = ""
+	}
+	return scenarios, nodes, nil
+}
+
+// checkScenarios writes a 400 response listing every problem check finds and returns false
+// if any scenario has problems.
+func checkScenarios(w http.ResponseWriter, scenarios []config.Scenario, nodes []*yaml.Node, check func(*config.Scenario, *yaml.Node) []config.Problem) bool {
+	var problems []config.Problem
+	for i := range scenarios {
+		var node *yaml.Node
+		if i < len(nodes) {
+			node = nodes[i]
+		}
+		problems = append(problems, check(&scenarios[i], node)...)
+	}
+	if len(problems) > 0 {
+		http.Error(w, "Invalid scenario:\n"+(&config.ValidationError{Problems: problems}).Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func validateScenario(s *config.Scenario, node *yaml.Node) []config.Problem {
+	return config.ValidateScenario(s, "", node)
+}
+
+// validateScenarios writes a 400 response listing every problem and returns false if any
+// scenario is invalid. Fields only scenario files may set are rejected first, so a rejected
+// bodyFile is never looked up.
+func validateScenarios(w http.ResponseWriter, scenarios []config.Scenario, nodes []*yaml.Node) bool {
+	return checkScenarios(w, scenarios, nodes, config.ValidateAdminScenario) &&
+		checkScenarios(w, scenarios, nodes, validateScenario)
+}
+
+// handleAddScenario adds new scenarios dynamically.
+// Scenarios with the ID of an existing one replace it; identical scenarios are not added twice.
+func handleAddScenario(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	scenarios, nodes, err := decodeScenarios(bodyBytes)
+	if err != nil {
+		http.Error(w, "Invalid scenario JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate every scenario before adding any
+	if !validateScenarios(w, scenarios, nodes) {
+		return
+	}
+
+	added := make([]*config.Scenario, 0, len(scenarios))
+	for i := range scenarios {
+		added = append(added, config.AddScenario(&scenarios[i]))
+	}
+
+	writeJSON(w, http.StatusOK, added)
+}
+
+// handleListScenarios returns every live scenario.
+func handleListScenarios(w http.ResponseWriter, r *http.Request) {
+	list := config.ListScenarios()
+	if list == nil {
+		list = []*config.Scenario{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleGetScenario returns a single scenario by ID.
+func handleGetScenario(w http.ResponseWriter, r *http.Request) {
+	s, ok := config.GetScenario(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Scenario not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// handlePutScenario creates or replaces the scenario with the given ID.
+func handlePutScenario(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	var s config.Scenario
+	if err := json.Unmarshal(bodyBytes, &s); err != nil {
+		http.Error(w, "Invalid scenario JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.ID != "" && s.ID != id {
+		http.Error(w, "Scenario id in body does not match the URL", http.StatusBadRequest)
+		return
+	}
+	s.ID = id
+	s.Source = ""
+	s.Profile = ""
+
+	var node *yaml.Node
+	var root yaml.Node
+	if err := yaml.Unmarshal(bodyBytes, &root); err == nil && len(root.Content) > 0 {
+		node = root.Content[0]
+	}
+	if !validateScenarios(w, []config.Scenario{s}, []*yaml.Node{node}) {
+		return
+	}
+
+	stored, created := config.PutScenario(id, &s)
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, stored)
+}
+
+// errIDChanged rejects a PATCH that changes the scenario ID
+var errIDChanged = errors.New("scenario id cannot be changed")
+
+// handlePatchScenario updates the top-level fields present in the body, e.g. {"disabled": true}.
+// The scenario is read, merged and stored under the config lock, so concurrent PATCHes of
+// different fields all apply.
+func handlePatchScenario(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	// Only the patch comes from the client; the fields it keeps may come from a scenario file
+	patch, nodes, err := decodeScenarios(bodyBytes)
+	if err != nil {
+		http.Error(w, "Invalid scenario JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !checkScenarios(w, patch, nodes, config.ValidateAdminScenario) {
+		return
+	}
+
+	stored, err := config.PatchScenario(id, func(s *config.Scenario) error {
+		patched, err := mergeScenario(s, bodyBytes)
+		if err != nil {
+			return err
+		}
+		if patched.ID != id {
+			return errIDChanged
+		}
+		if problems := config.ValidateScenario(patched, "", nil); len(problems) > 0 {
+			return &config.ValidationError{Problems: problems}
+		}
+		*s = *patched
+		return nil
+	})
+	var invalid *config.ValidationError
+	switch {
+	case errors.Is(err, config.ErrScenarioNotFound):
+		http.Error(w, "Scenario not found", http.StatusNotFound)
+	case errors.Is(err, errIDChanged):
+		http.Error(w, "Scenario id cannot be changed", http.StatusBadRequest)
+	case errors.As(err, &invalid):
+		http.Error(w, "Invalid scenario:\n"+invalid.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, "Invalid scenario JSON: "+err.Error(), http.StatusBadRequest)
+	default:
+		writeJSON(w, http.StatusOK, stored)
+	}
+}
+
+// handleDeleteScenario removes the scenario with the given ID.
+func handleDeleteScenario(w http.ResponseWriter, r *http.Request) {
+	if !config.DeleteScenario(mux.Vars(r)["id"]) {
+		http.Error(w, "Scenario not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleResetScenario rewinds a scenario to its first response and closes its circuit
+// breaker. With ?key=, only the sequence of that sequenceKey value is reset.
+func handleResetScenario(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if !config.ResetSequence(mux.Vars(r)["id"], key) {
+		if key != "" {
+			http.Error(w, "Scenario or sequence not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Scenario not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleExportScenarios returns the scenario set, with every profile, as a scenario file that
+// can be loaded again. Query parameters: format=yaml|json (default yaml), state=true to include
+// runtime state.
+func handleExportScenarios(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	withState, _ := strconv.ParseBool(r.URL.Query().Get("state"))
+
+	data, err := config.ExportScenarios(format, withState)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ext := "yaml"
+	contentType := "application/yaml"
+	if format == "json" {
+		ext = "json"
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="scenarios.`+ext+`"`)
+	_, _ = w.Write(data)
+}
+
+// scenarioOrderEntry describes one scenario in the evaluation order for a path and method
+type scenarioOrderEntry struct {
+	ID          string             `json:"id"`
+	Path        string             `json:"path"`
+	Disabled    bool               `json:"disabled,omitempty"`
+	Source      string             `json:"source,omitempty"`
+	Vars        map[string]string  `json:"vars,omitempty"`
+	Specificity faults.Specificity `json:"specificity"`
+}
+
+// handleScenarioOrder shows the order in which scenarios are evaluated for a request.
+// Query parameters: path (required) and method (default GET).
+func handleScenarioOrder(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path query parameter is required", http.StatusBadRequest)
+		return
+	}
+	method := r.URL.Query().Get("method")
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	order := []scenarioOrderEntry{}
+	for _, c := range faults.Candidates(strings.ToUpper(method), path) {
+		order = append(order, scenarioOrderEntry{
+			ID:          c.Scenario.ID,
+			Path:        c.Template,
+			Disabled:    c.Scenario.Disabled,
+			Source:      c.Scenario.Source,
+			Vars:        c.Vars,
+			Specificity: c.Specificity,
+		})
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+// handleNearMisses lists recent unmatched requests with the scenarios that came closest.
+// With a path query parameter, it explains instead how a request for that path would be
+// matched; only the method, path and query string are taken into account.
+func handleNearMisses(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeJSON(w, http.StatusOK, faults.UnmatchedRequests())
+		return
+	}
+	method := r.URL.Query().Get("method")
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), path, nil)
+	if err != nil || !strings.HasPrefix(req.URL.Path, "/") {
+		http.Error(w, "path must be an absolute path, optionally with a query string", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"method":     req.Method,
+		"path":       req.URL.Path,
+		"nearMisses": faults.NearMisses(req),
+	})
+}
+
+// handleResetNearMisses forgets the recorded unmatched requests.
+func handleResetNearMisses(w http.ResponseWriter, r *http.Request) {
+	faults.ResetUnmatchedRequests()
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("Near misses cleared."))
+}
+
+// handleScenarioSchema serves the JSON Schema for scenario files, for editor completion and validation.
+func handleScenarioSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(config.ScenarioSchema())
+}
+
+// handleGetState returns the current state of every state machine
+func handleGetState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, config.MachineStates())
+}
+
+// handleSetState moves a state machine to the state in the body, e.g. {"state": "created"}
+func handleSetState(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.State == "" {
+		http.Error(w, `Body must be {"state": "<name>"}`, http.StatusBadRequest)
+		return
+	}
+	machine := mux.Vars(r)["machine"]
+	config.SetMachineState(machine, body.State)
+	writeJSON(w, http.StatusOK, map[string]string{"machine": machine, "state": body.State})
+}
+
+// handleResetState moves every state machine, or those named by ?machine=, to the initial state
+func handleResetState(w http.ResponseWriter, r *http.Request) {
+	config.ResetMachineStates(r.URL.Query()["machine"]...)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("State machines reset."))
+}
+
+// handleResetResources restores the seed items of every resource, or of those named by ?path=
+func handleResetResources(w http.ResponseWriter, r *http.Request) {
+	config.ResetCollections(r.URL.Query()["path"]...)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("Resources reset."))
+}
+
+// handleGetProfile reports the active scenario profile and the profiles that are defined.
+func handleGetProfile(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"active":   config.ActiveProfile(),
+		"profiles": config.Profiles(),
+	})
+}
+
+// handleSetProfile switches the active scenario profile ("default" for the base scenarios).
+func handleSetProfile(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := config.SetProfile(name); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, config.ErrUnknownProfile) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	log.Printf("Switched to scenario profile %q", name)
+	writeJSON(w, http.StatusOK, map[string]string{"active": config.ActiveProfile()})
+}
+
+// mergeScenario returns a copy of the definition of s with the top-level fields present in
+// patch replaced. Fields are matched by their JSON name, case-insensitively like encoding/json.
+// The copy has no runtime state: storing it keeps the scenario's sequence and circuit breaker
+// if the patch changed nothing, and starts them over otherwise, as PUT does.
+func mergeScenario(s *config.Scenario, patch []byte) (*config.Scenario, error) {
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &present); err != nil {
+		return nil, err
+	}
+	var update config.Scenario
+	if err := json.Unmarshal(patch, &update); err != nil {
+		return nil, err
+	}
+
+	merged := s.Definition()
+
+	mergedValue := reflect.ValueOf(&merged).Elem()
+	updateValue := reflect.ValueOf(update)
+	for i := 0; i < mergedValue.NumField(); i++ {
+		name := strings.Split(mergedValue.Type().Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		for key := range present {
+			if strings.EqualFold(key, name) {
+				mergedValue.Field(i).Set(updateValue.Field(i))
+			}
+		}
+	}
+	merged.Source = s.Source
+	merged.Profile = s.Profile
+	return &merged, nil
+}
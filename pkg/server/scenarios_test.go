@@ -0,0 +1,396 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/arun0009/go-resilience-mock/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenarioCRUD(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Create
+	w := do("PUT", "/scenario/crud-test", `{"path": "/api/crud", "method": "GET", "responses": [{"status": 200, "body": "v1"}]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, 200, do("GET", "/api/crud", "").Code)
+
+	// Posting with the same ID replaces instead of stacking
+	w = do("POST", "/scenario", `{"id": "crud-test", "path": "/api/crud", "method": "GET", "responses": [{"status": 202}]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 202, do("GET", "/api/crud", "").Code)
+
+	// Read
+	w = do("GET", "/scenario/crud-test", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var got config.Scenario
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, "/api/crud", got.Path)
+
+	var list []config.Scenario
+	require.NoError(t, json.Unmarshal(do("GET", "/scenario", "").Body.Bytes(), &list))
+	count := 0
+	for _, s := range list {
+		if s.ID == "crud-test" {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count)
+
+	// Disable via PATCH, falls back to echo
+	w = do("PATCH", "/scenario/crud-test", `{"disabled": true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusOK, do("GET", "/api/crud", "").Code)
+	s, _ := config.GetScenario("crud-test")
+	assert.True(t, s.Disabled)
+	assert.Equal(t, 202, s.Responses[0].Status, "PATCH should keep fields not in the body")
+
+	assert.Equal(t, http.StatusBadRequest, do("PATCH", "/scenario/crud-test", `{"responses": []}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("PUT", "/scenario/crud-test", `{"id": "other", "path": "/x", "method": "GET", "responses": [{"status": 200}]}`).Code)
+
+	// Delete
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/scenario/crud-test", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/scenario/crud-test", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/scenario/crud-test", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/crud", "").Code)
+}
+
+func TestScenarioCRUD_RejectsFileOnlyFields(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A body file would let any client read files the server can read
+	w := do("POST", "/scenario", `{"path": "/api/leak", "method": "GET", "responses": [{"status": 200, "bodyFile": "/etc/passwd"}]}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "responses[0].bodyFile may only be set in scenario files")
+	w = do("POST", "/scenario", `{"path": "/api/leak", "method": "GET", "responses": [{"status": 200, "bodyFile": "/no/such/file"}]}`)
+	assert.NotContains(t, w.Body.String(), "no such file", "Rejected paths are never looked up")
+	assert.Equal(t, http.StatusBadRequest, do("PUT", "/scenario/leak", `{"path": "/api/leak", "method": "GET", "responses": [{"status": 200, "bodyFile": "../../etc/passwd"}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/scenario", `{"path": "/api/leak", "method": "GET", "resource": "/api/leak"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/leak", "").Code)
+
+	require.Equal(t, http.StatusCreated, do("PUT", "/scenario/leak", `{"path": "/api/leak", "method": "GET", "responses": [{"status": 200}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("PATCH", "/scenario/leak", `{"responses": [{"status": 200, "bodyFile": "/etc/passwd"}]}`).Code)
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/scenario/leak", "").Code)
+}
+
+func TestScenarioCRUD_UnderTraffic(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	v1 := `{"path": "/api/traffic", "method": "GET", "responses": [{"status": 200}, {"status": 201}]}`
+	v2 := `{"path": "/api/traffic", "method": "GET", "responses": [{"status": 202}, {"status": 203}]}`
+	require.Equal(t, http.StatusCreated, do("PUT", "/scenario/traffic", v1).Code)
+
+	// Run with -race: the admin API compares and copies scenarios that requests are advancing
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					do("GET", "/api/traffic", "")
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		body := v1
+		if i%2 == 1 {
+			body = v2
+		}
+		require.Equal(t, http.StatusOK, do("PUT", "/scenario/traffic", body).Code)
+		require.Equal(t, http.StatusOK, do("PUT", "/scenario/traffic", body).Code, "Unchanged PUT keeps the live scenario")
+		require.Equal(t, http.StatusOK, do("PATCH", "/scenario/traffic", `{"circuitBreaker": {"failureThreshold": 3, "timeout": 1000000000}}`).Code)
+		require.Equal(t, http.StatusOK, do("POST", "/scenario", `{"id": "traffic", "path": "/api/traffic", "method": "GET", "responses": [{"status": 200}]}`).Code)
+		do("GET", "/api/control/scenarios/export?state=true", "")
+	}
+	close(done)
+	wg.Wait()
+
+	// PATCH keeps the sequence when nothing changes and restarts it otherwise
+	require.Equal(t, http.StatusOK, do("PUT", "/scenario/traffic", v1).Code)
+	assert.Equal(t, 200, do("GET", "/api/traffic", "").Code)
+	require.Equal(t, http.StatusOK, do("PATCH", "/scenario/traffic", `{"disabled": false}`).Code)
+	assert.Equal(t, 201, do("GET", "/api/traffic", "").Code)
+	require.Equal(t, http.StatusOK, do("PATCH", "/scenario/traffic", `{"circuitBreaker": {"failureThreshold": 3, "timeout": 1000000000}}`).Code)
+	assert.Equal(t, 200, do("GET", "/api/traffic", "").Code)
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/scenario/traffic", "").Code)
+}
+
+func TestScenarioCRUD_ConcurrentPatches(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	initial := `{"path": "/api/patched", "method": "GET", "responses": [{"status": 200}]}`
+	require.Equal(t, http.StatusCreated, do("PUT", "/scenario/patched", initial).Code)
+	patches := []string{`{"disabled": true}`, `{"matches": {"headers": {"X-Tenant": "acme"}}}`, `{"circuitBreaker": {"failureThreshold": 3, "timeout": 1000000000}}`}
+
+	for round := 0; round < 20; round++ {
+		require.Equal(t, http.StatusOK, do("PUT", "/scenario/patched", initial).Code)
+
+		// Each PATCH changes a different field, so none may undo another
+		var wg sync.WaitGroup
+		for _, patch := range patches {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.Equal(t, http.StatusOK, do("PATCH", "/scenario/patched", patch).Code)
+			}()
+		}
+		wg.Wait()
+
+		s, ok := config.GetScenario("patched")
+		require.True(t, ok)
+		assert.True(t, s.Disabled)
+		assert.Equal(t, config.StringMatcher{Equals: "acme"}, s.Matches.Headers["X-Tenant"])
+		assert.Equal(t, 3, s.CircuitBreaker.FailureThreshold)
+	}
+	assert.Equal(t, http.StatusNotFound, do("PATCH", "/scenario/missing", `{"disabled": true}`).Code)
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/scenario/patched", "").Code)
+}
+
+func TestExportScenarios(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+	config.AddScenario(&config.Scenario{ID: "export-me", Path: "/api/export-me", Method: "GET", Responses: []config.Response{{Status: 200}}})
+
+	req := httptest.NewRequest("GET", "/api/control/scenarios/export?state=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "id: export-me")
+	assert.Contains(t, w.Body.String(), "hits: 0")
+
+	req = httptest.NewRequest("GET", "/api/control/scenarios/export?format=json", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var exported []config.Scenario
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &exported))
+
+	req = httptest.NewRequest("GET", "/api/control/scenarios/export?format=xml", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSetProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenarios.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+scenarios:
+  - path: /api/dependency
+    method: GET
+    responses:
+      - status: 200
+profiles:
+  outage:
+    - path: /api/dependency
+      method: GET
+      responses:
+        - status: 503
+`), 0o644))
+	_, err := config.LoadConfig(file)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = config.SetProfile(config.DefaultProfile) })
+
+	router := NewRouter(config.GetConfig())
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, do("GET", "/api/dependency").Code)
+
+	w := do("POST", "/api/control/profile/outage")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"active": "outage"}`, w.Body.String())
+	assert.Equal(t, http.StatusServiceUnavailable, do("GET", "/api/dependency").Code)
+	assert.Contains(t, do("GET", "/health").Body.String(), `"profile":"outage"`)
+	assert.JSONEq(t, `{"active": "outage", "profiles": ["outage"]}`, do("GET", "/api/control/profile").Body.String())
+
+	assert.Equal(t, http.StatusNotFound, do("POST", "/api/control/profile/missing").Code)
+
+	require.Equal(t, http.StatusOK, do("POST", "/api/control/profile/default").Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/api/dependency").Code)
+}
+
+func TestScenarioSchema(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/schema/scenarios", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/schema+json", w.Header().Get("Content-Type"))
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schema))
+	assert.Contains(t, schema["$defs"], "Scenario")
+}
+
+func TestScenarioOrder(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+	config.AddScenario(&config.Scenario{ID: "order-any", Path: "/api/order-test/{id}", Method: "GET", Responses: []config.Response{{Status: 200}}})
+	config.AddScenario(&config.Scenario{ID: "order-exact", Path: "/api/order-test/1", Method: "GET", Responses: []config.Response{{Status: 200}}})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/control/scenarios/order?method=get&path=/api/order-test/1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var order []struct {
+		ID   string            `json:"id"`
+		Vars map[string]string `json:"vars"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	require.Len(t, order, 2)
+	assert.Equal(t, "order-exact", order[0].ID)
+	assert.Equal(t, "order-any", order[1].ID)
+	assert.Equal(t, map[string]string{"id": "1"}, order[1].Vars)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/control/scenarios/order", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNearMisses(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+	config.AddScenario(&config.Scenario{ID: "nearmiss-admin", Path: "/api/nearmiss-test", Method: "GET",
+		Matches:   config.MatchConfig{Headers: map[string]config.StringMatcher{"X-Role": {Equals: "admin"}}},
+		Responses: []config.Response{{Status: 200}}})
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	var report struct {
+		NearMisses []struct {
+			ID         string `json:"id"`
+			Mismatches []struct {
+				Matcher string `json:"matcher"`
+			} `json:"mismatches"`
+		} `json:"nearMisses"`
+	}
+	w := do("GET", "/api/control/near-misses?path=/api/nearmiss-test")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.NotEmpty(t, report.NearMisses)
+	assert.Equal(t, "nearmiss-admin", report.NearMisses[0].ID)
+	assert.Equal(t, "matches.headers.X-Role", report.NearMisses[0].Mismatches[0].Matcher)
+
+	require.Equal(t, http.StatusOK, do("POST", "/api/control/reset-near-misses").Code)
+	w = do("GET", "/api/nearmiss-test")
+	assert.Equal(t, "true", w.Header().Get("X-Mock-Unmatched"))
+
+	var unmatched []struct {
+		Path string `json:"path"`
+	}
+	require.NoError(t, json.Unmarshal(do("GET", "/api/control/near-misses").Body.Bytes(), &unmatched))
+	require.Len(t, unmatched, 1)
+	assert.Equal(t, "/api/nearmiss-test", unmatched[0].Path)
+}
+
+func TestResetScenario(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+	config.AddScenario(&config.Scenario{ID: "reset-shards", Path: "/api/reset-test", Method: "GET",
+		Strategy:    config.StrategyStickyLast,
+		SequenceKey: config.SequenceKey{Header: "X-Shard"},
+		Responses:   []config.Response{{Status: 503}, {Status: 200}}})
+	do := func(method, path, shard string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Shard", shard)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, shard := range []string{"a", "b"} {
+		assert.Equal(t, http.StatusServiceUnavailable, do("GET", "/api/reset-test", shard))
+		assert.Equal(t, http.StatusOK, do("GET", "/api/reset-test", shard))
+	}
+
+	require.Equal(t, http.StatusNoContent, do("POST", "/scenario/reset-shards/reset?key=a", ""))
+	assert.Equal(t, http.StatusServiceUnavailable, do("GET", "/api/reset-test", "a"), "a starts over")
+	assert.Equal(t, http.StatusOK, do("GET", "/api/reset-test", "b"), "b keeps its position")
+	assert.Equal(t, http.StatusNotFound, do("POST", "/scenario/reset-shards/reset?key=c", ""), "c has no sequence")
+
+	require.Equal(t, http.StatusNoContent, do("POST", "/scenario/reset-shards/reset", ""))
+	assert.Equal(t, http.StatusServiceUnavailable, do("GET", "/api/reset-test", "b"))
+
+	assert.Equal(t, http.StatusNotFound, do("POST", "/scenario/missing/reset", ""))
+}
+
+func TestStateEndpoints(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+	config.AddScenario(&config.Scenario{Path: "/api/state-test", Method: "GET", StateMachine: "server-orders", WhenState: "shipped",
+		Responses: []config.Response{{Status: 200}}})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do("GET", "/api/control/state", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var states map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &states))
+	assert.Equal(t, config.InitialState, states["server-orders"])
+
+	assert.Equal(t, http.StatusBadRequest, do("PUT", "/api/control/state/server-orders", `{}`).Code)
+	require.Equal(t, http.StatusOK, do("PUT", "/api/control/state/server-orders", `{"state": "shipped"}`).Code)
+	assert.Equal(t, "shipped", config.MachineState("server-orders"))
+	assert.Equal(t, http.StatusOK, do("GET", "/api/state-test", "").Code)
+
+	require.Equal(t, http.StatusOK, do("POST", "/api/control/reset-state?machine=server-orders", "").Code)
+	assert.Equal(t, config.InitialState, config.MachineState("server-orders"))
+}
+
+func TestResetResources(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "resources.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("resources:\n  - path: /api/reset-items\n    seed:\n      - id: 1\n"), 0o644))
+	_, err := config.LoadConfig(file)
+	require.NoError(t, err)
+	router := NewRouter(config.GetConfig())
+	do := func(method, path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, do("DELETE", "/api/reset-items/1"))
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/reset-items/1"))
+
+	require.Equal(t, http.StatusOK, do("POST", "/api/control/reset-resources?path=/api/reset-items"))
+	assert.Equal(t, http.StatusOK, do("GET", "/api/reset-items/1"), "The seed is restored")
+}
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +13,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -86,7 +89,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if cfg.EnableCORS {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
 			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Echo-Delay, X-Echo-Status, X-Echo-Headers, X-Echo-Body")
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -219,6 +222,9 @@ func NewRouter(cfg config.Config) *mux.Router {
 	h.AddCheck("ping", func() (string, error) {
 		return "pong", nil
 	})
+	h.AddCheck("profile", func() (string, error) {
+		return config.ActiveProfile(), nil
+	})
 	router.HandleFunc("/health", h.Handler().ServeHTTP).Methods("GET")
 
 	// Stress
@@ -228,27 +234,34 @@ func NewRouter(cfg config.Config) *mux.Router {
 	// Control / Reset
 	router.HandleFunc("/api/control/reset-history", handleResetHistory).Methods("POST")
 	router.HandleFunc("/api/control/reset-metrics", handleResetMetrics).Methods("POST")
+	router.HandleFunc("/api/control/scenarios/export", handleExportScenarios).Methods("GET")
+	router.HandleFunc("/api/control/scenarios/order", handleScenarioOrder).Methods("GET")
+	router.HandleFunc("/api/control/near-misses", handleNearMisses).Methods("GET")
+	router.HandleFunc("/api/control/reset-near-misses", handleResetNearMisses).Methods("POST")
+	router.HandleFunc("/api/control/state", handleGetState).Methods("GET")
+	router.HandleFunc("/api/control/state/{machine}", handleSetState).Methods("PUT")
+	router.HandleFunc("/api/control/reset-state", handleResetState).Methods("POST")
+	router.HandleFunc("/api/control/reset-resources", handleResetResources).Methods("POST")
+	router.HandleFunc("/api/control/profile", handleGetProfile).Methods("GET")
+	router.HandleFunc("/api/control/profile/{name}", handleSetProfile).Methods("POST")
+	router.HandleFunc("/api/schema/scenarios", handleScenarioSchema).Methods("GET")
 
 	// Core
 	router.HandleFunc("/echo", faults.HandleEcho).Methods("GET", "POST", "PUT", "DELETE", "PATCH")
 	router.HandleFunc("/history", handleHistory).Methods("GET")
 	router.HandleFunc("/replay", handleReplay).Methods("POST")
 	router.HandleFunc("/scenario", handleAddScenario).Methods("POST")
+	router.HandleFunc("/scenario", handleListScenarios).Methods("GET")
+	router.HandleFunc("/scenario/{id}", handleGetScenario).Methods("GET")
+	router.HandleFunc("/scenario/{id}", handlePutScenario).Methods("PUT")
+	router.HandleFunc("/scenario/{id}", handlePatchScenario).Methods("PATCH")
+	router.HandleFunc("/scenario/{id}", handleDeleteScenario).Methods("DELETE")
+	router.HandleFunc("/scenario/{id}/reset", handleResetScenario).Methods("POST")
 
 	// Streaming
 	router.HandleFunc("/ws", faults.HandleWebsocket)
 	router.HandleFunc("/sse", faults.HandleSSE)
 
-	// Scenarios
-	config.GetScenarios().Range(func(key, value interface{}) bool {
-		// key is path_method.
-		// parts[0] is the path template (e.g. /users/{id})
-		// parts[1] is the method
-		parts := strings.Split(key.(string), "_")
-		router.HandleFunc(parts[0], faults.HandleScenario).Methods(parts[1])
-		return true
-	})
-
 	router.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "docs/favicon.ico")
 	})
@@ -266,96 +279,14 @@ func NewRouter(cfg config.Config) *mux.Router {
 
 	router.PathPrefix("/docs/").Handler(http.StripPrefix("/docs/", http.FileServer(http.Dir("docs"))))
 
-	// Catch-all: Check if it matches a dynamic scenario, otherwise 404
-	router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 1. Try exact match first (fast path)
-		key := r.URL.Path + "_" + r.Method
-		if _, ok := config.GetScenarios().Load(key); ok {
-			faults.HandleScenario(w, r)
-			return
-		}
-
-		// 2. Try matching path templates (slow path)
-		// We iterate through all scenarios to see if any path template matches the current request
-		var matched bool
-		config.GetScenarios().Range(func(k, v interface{}) bool {
-			scenarioKey := k.(string)
-			parts := strings.Split(scenarioKey, "_")
-			if len(parts) != 2 {
-				return true
-			}
-			tmplPath := parts[0]
-			method := parts[1]
-
-			if method != r.Method {
-				return true
-			}
-
-			// Check if template matches
-			// We use a temporary router to check for match and extract vars
-			// This is inefficient for high throughput but functional for a mock server
-			// A better approach would be to dynamically update the main router, but gorilla/mux doesn't support that easily.
-
-			// Simple check: does it look like a template?
-			if strings.Contains(tmplPath, "{") {
-				// Manual matching logic or use a helper
-				// For simplicity, let's try to match using a throwaway route match
-				// This is tricky without access to the route infrastructure.
-
-				// Alternative: We can't easily use gorilla/mux matching here without registering a route.
-				// But we can try to register it on a temporary router? No, too slow.
-
-				// Let's implement a basic path matcher for {id} style vars
-				vars, matches := matchPath(tmplPath, r.URL.Path)
-				if matches {
-					// Inject vars into context so HandleScenario can use them
-					r = mux.SetURLVars(r, vars)
-					// We found a match, but we need to pass the *Scenario* or let HandleScenario find it.
-					// HandleScenario looks up by r.URL.Path which won't work.
-					// We need to modify HandleScenario or pass the matched template path.
-
-					// Hack: We can temporarily set the URL path to the template path?
-					// No, that breaks other things.
-					// Better: Put the template path in context.
-					ctx := context.WithValue(r.Context(), faults.MatchedTemplateKey, tmplPath)
-					faults.HandleScenario(w, r.WithContext(ctx))
-					matched = true
-					return false // Stop iteration
-				}
-			}
-			return true
-		})
-
-		if !matched {
-			http.NotFound(w, r)
-		}
-	})
+	// Catch-all: Check if it matches a scenario, otherwise 404.
+	// Scenarios are not registered as mux routes so that reloading the scenario file
+	// swaps the routing table along with the scenario set.
+	router.PathPrefix("/").HandlerFunc(faults.HandleScenario)
 
 	return router
 }
 
-// matchPath checks if a request path matches a template path (e.g. /users/{id})
-// and returns the extracted variables.
-func matchPath(template, path string) (map[string]string, bool) {
-	tmplParts := strings.Split(strings.Trim(template, "/"), "/")
-	pathParts := strings.Split(strings.Trim(path, "/"), "/")
-
-	if len(tmplParts) != len(pathParts) {
-		return nil, false
-	}
-
-	vars := make(map[string]string)
-	for i, part := range tmplParts {
-		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
-			key := part[1 : len(part)-1]
-			vars[key] = pathParts[i]
-		} else if part != pathParts[i] {
-			return nil, false
-		}
-	}
-	return vars, true
-}
-
 // Run starts the HTTP server.
 func Run(cfg config.Config) error {
 	printBanner(cfg)
@@ -381,6 +312,20 @@ func RunTLS(cfg config.Config) error {
 		IdleTimeout:  0,
 	}
 
+	// Client certificates are optional, so other callers are unaffected; when given, they
+	// are verified and can be matched with matches.clientCertSubject
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("reading client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("client CA file %s contains no PEM certificates", cfg.ClientCAFile)
+		}
+		server.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	}
+
 	log.Printf("Starting HTTPS server on port %s", cfg.Port)
 	return server.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
 }
@@ -538,28 +483,3 @@ func handleReplay(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(resp.StatusCode)
 	_, _ = io.Copy(w, resp.Body)
 }
-
-// handleAddScenario adds a new scenario dynamically.
-func handleAddScenario(w http.ResponseWriter, r *http.Request) {
-	var scenarios []config.Scenario
-	bodyBytes, _ := io.ReadAll(r.Body)
-	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-
-	// Try parsing as a list of scenarios first
-	if err := json.Unmarshal(bodyBytes, &scenarios); err != nil {
-		// Try parsing as a single scenario
-		var s config.Scenario
-		if err2 := json.Unmarshal(bodyBytes, &s); err2 != nil {
-			http.Error(w, "Invalid scenario JSON: "+err2.Error(), http.StatusBadRequest)
-			return
-		}
-		scenarios = []config.Scenario{s}
-	}
-
-	for i := range scenarios {
-		config.AddScenario(&scenarios[i])
-	}
-
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("Scenarios added."))
-}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/arun0009/go-resilience-mock/pkg/config"
@@ -81,3 +82,21 @@ func TestDynamicPathParameters_Reproduction(t *testing.T) {
 	// 3. Assert
 	assert.Equal(t, 200, resp.StatusCode, "Expected 200 OK for dynamic path match")
 }
+
+func TestAddScenario_Validation(t *testing.T) {
+	router := NewRouter(config.GetConfig())
+
+	body := `[
+  {"path": "/api/valid", "method": "GET", "responses": [{"status": 200}]},
+  {"path": "/api/invalid", "method": "GET", "responses": []}
+]`
+	req := httptest.NewRequest("POST", "/scenario", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "3:58: GET /api/invalid: at least one response is required")
+
+	_, ok := config.GetScenarios().Load("/api/valid_GET")
+	assert.False(t, ok, "No scenario should be added when any is invalid")
+}